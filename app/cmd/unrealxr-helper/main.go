@@ -0,0 +1,92 @@
+// The UnrealXR privileged helper. This runs as root and only performs the few operations that need root (see privhelper.Operations),
+// so that the rest of UnrealXR can run as the desktop user.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/privhelper"
+	"github.com/charmbracelet/log"
+)
+
+// Gets the UID of the user that invoked us through a privilege escalation tool. pkexec, sudo and run0 export it directly,
+// while doas only exports the user name
+func invokingUID() (int, error) {
+	for _, variable := range []string{"PKEXEC_UID", "SUDO_UID"} {
+		value := os.Getenv(variable)
+
+		if value == "" {
+			continue
+		}
+
+		uid, err := strconv.Atoi(value)
+
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s '%s': %w", variable, value, err)
+		}
+
+		return uid, nil
+	}
+
+	if username := os.Getenv("DOAS_USER"); username != "" {
+		invokingUser, err := user.Lookup(username)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to look up DOAS_USER '%s': %w", username, err)
+		}
+
+		uid, err := strconv.Atoi(invokingUser.Uid)
+
+		if err != nil {
+			return 0, fmt.Errorf("invalid UID '%s' of user '%s': %w", invokingUser.Uid, username, err)
+		}
+
+		return uid, nil
+	}
+
+	return 0, fmt.Errorf("no supported privilege escalation tool told us who invoked us")
+}
+
+func main() {
+	log.SetPrefix("helper")
+
+	if level, err := log.ParseLevel(os.Getenv("UNREALXR_LOG_LEVEL")); err == nil {
+		log.SetLevel(level)
+	}
+
+	socketPath := flag.String("socket", "", "path of the Unix socket to listen on")
+	clientUID := flag.Int("uid", -1, "UID of the user allowed to connect")
+	flag.Parse()
+
+	if *socketPath == "" || *clientUID < 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if os.Geteuid() != 0 {
+		log.Fatal("The privileged helper must be run as root")
+	}
+
+	// Only serve the user that authenticated, so nobody else can get a helper for themselves
+	uid, err := invokingUID()
+
+	if err != nil {
+		log.Fatalf("Refusing to run, as the invoking user couldn't be determined: %s", err.Error())
+	}
+
+	if uid != *clientUID {
+		log.Fatalf("Refusing to serve UID %d when invoked by UID %d", *clientUID, uid)
+	}
+
+	err = privhelper.Serve(*socketPath, *clientUID, func(format string, args ...any) {
+		log.Debugf(format, args...)
+	})
+
+	if err != nil {
+		log.Fatalf("Fatal error during execution: %s", err.Error())
+	}
+}
@@ -1,6 +1,14 @@
 package config
 
-import _ "embed"
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/layout"
+	arcommons "git.lunr.sh/UnrealXR/unrealxr/ardriver/commons"
+)
 
 //go:embed default_config.yml
 var InitialConfig []byte
@@ -12,8 +20,38 @@ type DisplayConfig struct {
 	RadiusMultiplier   *float32 `yaml:"circle_radius_multiplier"`
 	UseCircularSpacing *bool    `yaml:"use_circular_spacing"`
 	Count              *int     `yaml:"count"`
+	BlankedBehavior    *string  `yaml:"blanked_behavior"`
+	IdleFPS            *int     `yaml:"idle_fps"`
+	Anchoring          *string  `yaml:"anchoring"`
+	// Anchoring of single displays, by display number (starting at 0)
+	DisplayAnchoring map[int]string `yaml:"display_anchoring"`
+	FollowDeadZone   *int           `yaml:"follow_dead_zone"`
+	FollowEasing     *float32       `yaml:"follow_easing"`
+	Curved           *bool          `yaml:"curved"`
+	// Whether single displays are curved, by display number (starting at 0)
+	DisplayCurved map[int]bool `yaml:"display_curved"`
+	// Radius of curved displays. 0 derives it from the layout
+	CurveRadius *float32 `yaml:"curve_radius"`
+	// Key that toggles the layout editor
+	EditKey *string `yaml:"edit_key"`
+	// How close displays need to be to lining up before the layout editor snaps them, in degrees. 0 disables snapping
+	SnapAngle *float32 `yaml:"snap_angle"`
 }
 
+// What to show for virtual displays that the compositor turned off
+const (
+	BlankedBehaviorHidden      = "hidden"
+	BlankedBehaviorDimmed      = "dimmed"
+	BlankedBehaviorPlaceholder = "placeholder"
+)
+
+// How virtual displays move along with the head
+const (
+	AnchoringWorld  = "world"
+	AnchoringHead   = "head"
+	AnchoringFollow = "follow"
+)
+
 type AppOverrides struct {
 	AllowUnsupportedDevices *bool `yaml:"allow_unsupported_devices"`
 	OverrideWidth           *int  `yaml:"width"`
@@ -21,9 +59,214 @@ type AppOverrides struct {
 	OverrideRefreshRate     *int  `yaml:"refresh_rate"`
 }
 
+// Privilege modes
+const (
+	PrivilegeModeHelper = "helper"
+	PrivilegeModeLegacy = "legacy"
+)
+
+// Privilege escalation tools that can be used to get root. "auto" picks the first available one
+var EscalationBackends = []string{"auto", "pkexec", "run0", "sudo", "doas"}
+
+type PrivilegeConfig struct {
+	Mode                 *string  `yaml:"mode"`
+	EscalationBackend    *string  `yaml:"escalation_backend"`
+	EnvironmentAllowlist []string `yaml:"environment_allowlist"`
+}
+
+type HeadTrackingConfig struct {
+	RecenterPitch      *bool        `yaml:"recenter_pitch"`
+	RecenterDuration   *float32     `yaml:"recenter_duration"`
+	RecenterKey        *string      `yaml:"recenter_key"`
+	RecenterButton     *string      `yaml:"recenter_button"`
+	AutoRecenter       *bool        `yaml:"auto_recenter"`
+	AutoRecenterAngle  *int         `yaml:"auto_recenter_angle"`
+	AutoRecenterDelay  *float32     `yaml:"auto_recenter_delay"`
+	Prediction         *string      `yaml:"prediction"`
+	PredictionInterval *float32     `yaml:"prediction_interval"`
+	Filter             FilterConfig `yaml:"filter"`
+}
+
+// Filters that can be applied to head tracking
+const (
+	FilterNone        = "none"
+	FilterExponential = "exponential"
+	FilterOneEuro     = "one_euro"
+)
+
+type AxisFilterConfig struct {
+	Filter *string `yaml:"filter"`
+	// Cutoff frequency in Hz. For One Euro filters, this is the cutoff while the head is still
+	MinCutoff *float32 `yaml:"min_cutoff"`
+	// How much One Euro filters raise the cutoff frequency per radian per second of head movement
+	Beta *float32 `yaml:"beta"`
+}
+
+type FilterConfig struct {
+	Yaw   AxisFilterConfig `yaml:"yaw"`
+	Pitch AxisFilterConfig `yaml:"pitch"`
+	Roll  AxisFilterConfig `yaml:"roll"`
+}
+
+// How far ahead the head orientation is predicted
+const (
+	PredictionOff   = "off"
+	PredictionAuto  = "auto"
+	PredictionFixed = "fixed"
+)
+
+// How the pointer is moved to the focused display
+const (
+	PointerWarpNone   = "none"
+	PointerWarpUinput = "uinput"
+)
+
+type FocusConfig struct {
+	// Draws an outline around the display that is looked at
+	Highlight *bool `yaml:"highlight"`
+	// How long another display needs to be looked at before it gets focus, in seconds
+	DwellTime   *float32 `yaml:"dwell_time"`
+	PointerWarp *string  `yaml:"pointer_warp"`
+}
+
+// Moves the pointer with the head while the view stays put
+type HeadPointerConfig struct {
+	Enabled *bool `yaml:"enabled"`
+	// Pixels the pointer moves per degree of head motion
+	Gain *float32 `yaml:"gain"`
+	// Head motion that is taken up before the pointer moves, in degrees
+	DeadZone     *float32 `yaml:"dead_zone"`
+	ClutchKey    *string  `yaml:"clutch_key"`
+	ClutchButton *string  `yaml:"clutch_button"`
+}
+
+// Brings the focused display closer, so small text is easier to read
+type ZoomConfig struct {
+	InKey           *string `yaml:"in_key"`
+	OutKey          *string `yaml:"out_key"`
+	PixelPerfectKey *string `yaml:"pixel_perfect_key"`
+	// How much each zoom step magnifies the display
+	Step *float32 `yaml:"step"`
+	// Displays can be magnified up to this, and shrunk down to its inverse
+	MaxMagnification *float32 `yaml:"max_magnification"`
+	// Time constant of the zoom animation, in seconds. 0 zooms instantly
+	Easing *float32 `yaml:"easing"`
+}
+
+// How display textures are sampled
+const (
+	TextureFilterPoint     = "point"
+	TextureFilterBilinear  = "bilinear"
+	TextureFilterTrilinear = "trilinear"
+)
+
+type RenderingConfig struct {
+	TextureFilter *string `yaml:"texture_filter"`
+	// Level of anisotropic filtering (4, 8 or 16), or 0 to disable it
+	Anisotropy *int `yaml:"anisotropy"`
+	// Smooths the edges of the displays with 4x multisampling
+	MSAA *bool `yaml:"msaa"`
+	// Renders at this many times the resolution of the glasses and scales the result down. 1 disables it
+	Supersampling *float32 `yaml:"supersampling"`
+}
+
+// Widgets the HUD can show
+const (
+	HUDWidgetClock   = "clock"
+	HUDWidgetFPS     = "fps"
+	HUDWidgetIMU     = "imu"
+	HUDWidgetBattery = "battery"
+)
+
+// When the HUD shows its widgets
+const (
+	HUDShowAlways   = "always"
+	HUDShowLookDown = "look_down"
+)
+
+// Head-locked overlay with notifications and status widgets
+type HUDConfig struct {
+	Enabled *bool `yaml:"enabled"`
+	// How long notifications stay up, in seconds
+	ToastDuration *float32 `yaml:"toast_duration"`
+	// How long notifications and widgets take to fade in and out, in seconds
+	FadeDuration *float32 `yaml:"fade_duration"`
+	Widgets      []string `yaml:"widgets"`
+	ShowWidgets  *string  `yaml:"show_widgets"`
+}
+
+// Disables a key or button binding
+const BindingNone = "none"
+
+type StereoConfig struct {
+	Enabled *bool `yaml:"enabled"`
+	// Distance between the eyes, in millimeters
+	IPD *float32 `yaml:"ipd"`
+	// Distance at which the eye views converge, in meters
+	ConvergenceDistance *float32 `yaml:"convergence_distance"`
+	// Distance at which the displays appear, in meters
+	DisplayDistance *float32 `yaml:"display_distance"`
+}
+
+type DisplayPoseConfig struct {
+	// Center of the display relative to the viewer: right, up and backwards, in world units
+	Position []float32 `yaml:"position,flow,omitempty"`
+	// Yaw, pitch and roll, in degrees
+	Rotation []float32 `yaml:"rotation,flow,omitempty"`
+	Scale    *float32  `yaml:"scale,omitempty"`
+}
+
+type LayoutConfig struct {
+	Type    *string `yaml:"type"`
+	Columns *int    `yaml:"columns"`
+	// Poses of single displays, by display number (starting at 0). These override what the layout generates
+	Displays map[int]*DisplayPoseConfig `yaml:"displays"`
+}
+
+// What is drawn behind the displays
+const (
+	BackgroundSolid  = "solid"
+	BackgroundSkybox = "skybox"
+	BackgroundGrid   = "grid"
+	BackgroundRoom   = "room"
+)
+
+type BackgroundConfig struct {
+	Type *string `yaml:"type"`
+	// Fills everything behind the displays, as "#rrggbb"
+	Color *string `yaml:"color"`
+	// How strongly the color covers the real world, from 0 to 1
+	Opacity *float32 `yaml:"opacity"`
+	// Equirectangular 360° image for skyboxes. Relative paths start at the config directory
+	Image *string `yaml:"image"`
+	// How much the background is darkened, from 0 to 1
+	Dim *float32 `yaml:"dim"`
+	// Dim levels by blend state of the glasses, which replace dim while the glasses are in that state
+	BlendDim map[string]float32 `yaml:"blend_dim"`
+}
+
+// Settings that can differ between profiles
+type ProfileConfig struct {
+	Stereo     StereoConfig     `yaml:"stereo"`
+	Layout     LayoutConfig     `yaml:"layout"`
+	Background BackgroundConfig `yaml:"background"`
+}
+
+// Profile that is created if the config doesn't have any
+const DefaultProfileName = "default"
+
 type Config struct {
-	DisplayConfig DisplayConfig `yaml:"display"`
-	Overrides     AppOverrides  `yaml:"overrides"`
+	DisplayConfig DisplayConfig             `yaml:"display"`
+	HeadTracking  HeadTrackingConfig        `yaml:"head_tracking"`
+	Focus         FocusConfig               `yaml:"focus"`
+	HeadPointer   HeadPointerConfig         `yaml:"head_pointer"`
+	Zoom          ZoomConfig                `yaml:"zoom"`
+	Rendering     RenderingConfig           `yaml:"rendering"`
+	HUD           HUDConfig                 `yaml:"hud"`
+	Profile       *string                   `yaml:"profile"`
+	Profiles      map[string]*ProfileConfig `yaml:"profiles"`
+	Overrides     AppOverrides              `yaml:"overrides"`
+	Privileges    PrivilegeConfig           `yaml:"privileges"`
 }
 
 func getPtrToInt(int int) *int {
@@ -38,6 +281,10 @@ func getPtrToBool(bool bool) *bool {
 	return &bool
 }
 
+func getPtrToString(string string) *string {
+	return &string
+}
+
 var DefaultConfig = &Config{
 	DisplayConfig: DisplayConfig{
 		Angle:              getPtrToInt(45),
@@ -46,10 +293,179 @@ var DefaultConfig = &Config{
 		RadiusMultiplier:   getPtrToFloat32(2),
 		UseCircularSpacing: getPtrToBool(true),
 		Count:              getPtrToInt(3),
+		BlankedBehavior:    getPtrToString(BlankedBehaviorPlaceholder),
+		IdleFPS:            getPtrToInt(10),
+		Anchoring:          getPtrToString(AnchoringWorld),
+		FollowDeadZone:     getPtrToInt(30),
+		FollowEasing:       getPtrToFloat32(0.25),
+		Curved:             getPtrToBool(false),
+		CurveRadius:        getPtrToFloat32(0),
+		EditKey:            getPtrToString("f11"),
+		SnapAngle:          getPtrToFloat32(3),
+	},
+	HeadTracking: HeadTrackingConfig{
+		RecenterPitch:      getPtrToBool(false),
+		RecenterDuration:   getPtrToFloat32(0.4),
+		RecenterKey:        getPtrToString("f12"),
+		RecenterButton:     getPtrToString(BindingNone),
+		AutoRecenter:       getPtrToBool(false),
+		AutoRecenterAngle:  getPtrToInt(60),
+		AutoRecenterDelay:  getPtrToFloat32(10),
+		Prediction:         getPtrToString(PredictionAuto),
+		PredictionInterval: getPtrToFloat32(16),
+		Filter: FilterConfig{
+			Yaw: AxisFilterConfig{
+				Filter:    getPtrToString(FilterNone),
+				MinCutoff: getPtrToFloat32(1),
+				Beta:      getPtrToFloat32(0.5),
+			},
+			Pitch: AxisFilterConfig{
+				Filter:    getPtrToString(FilterNone),
+				MinCutoff: getPtrToFloat32(1),
+				Beta:      getPtrToFloat32(0.5),
+			},
+			Roll: AxisFilterConfig{
+				Filter:    getPtrToString(FilterNone),
+				MinCutoff: getPtrToFloat32(1),
+				Beta:      getPtrToFloat32(0.5),
+			},
+		},
+	},
+	Focus: FocusConfig{
+		Highlight:   getPtrToBool(false),
+		DwellTime:   getPtrToFloat32(0.3),
+		PointerWarp: getPtrToString(PointerWarpNone),
 	},
+	HeadPointer: HeadPointerConfig{
+		Enabled:      getPtrToBool(false),
+		Gain:         getPtrToFloat32(40),
+		DeadZone:     getPtrToFloat32(0.3),
+		ClutchKey:    getPtrToString("f10"),
+		ClutchButton: getPtrToString(BindingNone),
+	},
+	Zoom: ZoomConfig{
+		InKey:            getPtrToString("f8"),
+		OutKey:           getPtrToString("f7"),
+		PixelPerfectKey:  getPtrToString("f9"),
+		Step:             getPtrToFloat32(1.25),
+		MaxMagnification: getPtrToFloat32(4),
+		Easing:           getPtrToFloat32(0.1),
+	},
+	Rendering: RenderingConfig{
+		TextureFilter: getPtrToString(TextureFilterPoint),
+		Anisotropy:    getPtrToInt(0),
+		MSAA:          getPtrToBool(false),
+		Supersampling: getPtrToFloat32(1),
+	},
+	HUD: HUDConfig{
+		Enabled:       getPtrToBool(true),
+		ToastDuration: getPtrToFloat32(3),
+		FadeDuration:  getPtrToFloat32(0.3),
+		Widgets:       []string{},
+		ShowWidgets:   getPtrToString(HUDShowAlways),
+	},
+	Profile: getPtrToString(DefaultProfileName),
 	Overrides: AppOverrides{
 		AllowUnsupportedDevices: getPtrToBool(false),
 	},
+	Privileges: PrivilegeConfig{
+		Mode:              getPtrToString(PrivilegeModeHelper),
+		EscalationBackend: getPtrToString("auto"),
+		EnvironmentAllowlist: []string{
+			"UNREALXR_LOG_LEVEL",
+			"WAYLAND_DISPLAY",
+			"DISPLAY",
+			"XAUTHORITY",
+		},
+	},
+}
+
+// Values of settings that a profile leaves out
+var DefaultProfile = ProfileConfig{
+	Stereo: StereoConfig{
+		Enabled:             getPtrToBool(false),
+		IPD:                 getPtrToFloat32(63),
+		ConvergenceDistance: getPtrToFloat32(2),
+		DisplayDistance:     getPtrToFloat32(2),
+	},
+	Layout: LayoutConfig{
+		Columns: getPtrToInt(2),
+	},
+	Background: BackgroundConfig{
+		Type:    getPtrToString(BackgroundSolid),
+		Color:   getPtrToString("#000000"),
+		Opacity: getPtrToFloat32(1),
+		Image:   getPtrToString(""),
+		Dim:     getPtrToFloat32(0),
+	},
+}
+
+func initializeProfile(profile *ProfileConfig, config *Config) {
+	if profile.Stereo.Enabled == nil {
+		profile.Stereo.Enabled = DefaultProfile.Stereo.Enabled
+	}
+
+	if profile.Stereo.IPD == nil {
+		profile.Stereo.IPD = DefaultProfile.Stereo.IPD
+	}
+
+	if profile.Stereo.ConvergenceDistance == nil {
+		profile.Stereo.ConvergenceDistance = DefaultProfile.Stereo.ConvergenceDistance
+	}
+
+	if profile.Stereo.DisplayDistance == nil {
+		profile.Stereo.DisplayDistance = DefaultProfile.Stereo.DisplayDistance
+	}
+
+	// Configs from before layouts existed chose between these two
+	if profile.Layout.Type == nil && *config.DisplayConfig.UseCircularSpacing {
+		profile.Layout.Type = getPtrToString(layout.KindArc)
+	} else if profile.Layout.Type == nil {
+		profile.Layout.Type = getPtrToString(layout.KindRow)
+	}
+
+	if profile.Layout.Columns == nil {
+		profile.Layout.Columns = DefaultProfile.Layout.Columns
+	}
+
+	if profile.Background.Type == nil {
+		profile.Background.Type = DefaultProfile.Background.Type
+	}
+
+	if profile.Background.Color == nil {
+		profile.Background.Color = DefaultProfile.Background.Color
+	}
+
+	if profile.Background.Opacity == nil {
+		profile.Background.Opacity = DefaultProfile.Background.Opacity
+	}
+
+	if profile.Background.Image == nil {
+		profile.Background.Image = DefaultProfile.Background.Image
+	}
+
+	if profile.Background.Dim == nil {
+		profile.Background.Dim = DefaultProfile.Background.Dim
+	}
+}
+
+// Gets the profile that is in use
+func ActiveProfile(config *Config) *ProfileConfig {
+	return config.Profiles[*config.Profile]
+}
+
+func initializeAxisFilter(axis *AxisFilterConfig, defaults AxisFilterConfig) {
+	if axis.Filter == nil {
+		axis.Filter = defaults.Filter
+	}
+
+	if axis.MinCutoff == nil {
+		axis.MinCutoff = defaults.MinCutoff
+	}
+
+	if axis.Beta == nil {
+		axis.Beta = defaults.Beta
+	}
 }
 
 func InitializePotentiallyMissingConfigValues(config *Config) {
@@ -66,10 +482,186 @@ func InitializePotentiallyMissingConfigValues(config *Config) {
 		config.DisplayConfig.Spacing = DefaultConfig.DisplayConfig.Spacing
 	}
 
+	if config.DisplayConfig.RadiusMultiplier == nil {
+		config.DisplayConfig.RadiusMultiplier = DefaultConfig.DisplayConfig.RadiusMultiplier
+	}
+
+	if config.DisplayConfig.UseCircularSpacing == nil {
+		config.DisplayConfig.UseCircularSpacing = DefaultConfig.DisplayConfig.UseCircularSpacing
+	}
+
 	if config.DisplayConfig.Count == nil {
 		config.DisplayConfig.Count = DefaultConfig.DisplayConfig.Count
 	}
 
+	if config.DisplayConfig.BlankedBehavior == nil {
+		config.DisplayConfig.BlankedBehavior = DefaultConfig.DisplayConfig.BlankedBehavior
+	}
+
+	if config.DisplayConfig.IdleFPS == nil {
+		config.DisplayConfig.IdleFPS = DefaultConfig.DisplayConfig.IdleFPS
+	}
+
+	if config.DisplayConfig.Anchoring == nil {
+		config.DisplayConfig.Anchoring = DefaultConfig.DisplayConfig.Anchoring
+	}
+
+	if config.DisplayConfig.FollowDeadZone == nil {
+		config.DisplayConfig.FollowDeadZone = DefaultConfig.DisplayConfig.FollowDeadZone
+	}
+
+	if config.DisplayConfig.FollowEasing == nil {
+		config.DisplayConfig.FollowEasing = DefaultConfig.DisplayConfig.FollowEasing
+	}
+
+	if config.DisplayConfig.Curved == nil {
+		config.DisplayConfig.Curved = DefaultConfig.DisplayConfig.Curved
+	}
+
+	if config.DisplayConfig.CurveRadius == nil {
+		config.DisplayConfig.CurveRadius = DefaultConfig.DisplayConfig.CurveRadius
+	}
+
+	if config.DisplayConfig.EditKey == nil {
+		config.DisplayConfig.EditKey = DefaultConfig.DisplayConfig.EditKey
+	}
+
+	if config.DisplayConfig.SnapAngle == nil {
+		config.DisplayConfig.SnapAngle = DefaultConfig.DisplayConfig.SnapAngle
+	}
+
+	if config.HeadTracking.RecenterPitch == nil {
+		config.HeadTracking.RecenterPitch = DefaultConfig.HeadTracking.RecenterPitch
+	}
+
+	if config.HeadTracking.RecenterDuration == nil {
+		config.HeadTracking.RecenterDuration = DefaultConfig.HeadTracking.RecenterDuration
+	}
+
+	if config.HeadTracking.RecenterKey == nil {
+		config.HeadTracking.RecenterKey = DefaultConfig.HeadTracking.RecenterKey
+	}
+
+	if config.HeadTracking.RecenterButton == nil {
+		config.HeadTracking.RecenterButton = DefaultConfig.HeadTracking.RecenterButton
+	}
+
+	if config.HeadTracking.AutoRecenter == nil {
+		config.HeadTracking.AutoRecenter = DefaultConfig.HeadTracking.AutoRecenter
+	}
+
+	if config.HeadTracking.AutoRecenterAngle == nil {
+		config.HeadTracking.AutoRecenterAngle = DefaultConfig.HeadTracking.AutoRecenterAngle
+	}
+
+	if config.HeadTracking.AutoRecenterDelay == nil {
+		config.HeadTracking.AutoRecenterDelay = DefaultConfig.HeadTracking.AutoRecenterDelay
+	}
+
+	if config.HeadTracking.Prediction == nil {
+		config.HeadTracking.Prediction = DefaultConfig.HeadTracking.Prediction
+	}
+
+	if config.HeadTracking.PredictionInterval == nil {
+		config.HeadTracking.PredictionInterval = DefaultConfig.HeadTracking.PredictionInterval
+	}
+
+	initializeAxisFilter(&config.HeadTracking.Filter.Yaw, DefaultConfig.HeadTracking.Filter.Yaw)
+	initializeAxisFilter(&config.HeadTracking.Filter.Pitch, DefaultConfig.HeadTracking.Filter.Pitch)
+	initializeAxisFilter(&config.HeadTracking.Filter.Roll, DefaultConfig.HeadTracking.Filter.Roll)
+
+	if config.Focus.Highlight == nil {
+		config.Focus.Highlight = DefaultConfig.Focus.Highlight
+	}
+
+	if config.Focus.DwellTime == nil {
+		config.Focus.DwellTime = DefaultConfig.Focus.DwellTime
+	}
+
+	if config.Focus.PointerWarp == nil {
+		config.Focus.PointerWarp = DefaultConfig.Focus.PointerWarp
+	}
+
+	if config.HeadPointer.Enabled == nil {
+		config.HeadPointer.Enabled = DefaultConfig.HeadPointer.Enabled
+	}
+
+	if config.HeadPointer.Gain == nil {
+		config.HeadPointer.Gain = DefaultConfig.HeadPointer.Gain
+	}
+
+	if config.HeadPointer.DeadZone == nil {
+		config.HeadPointer.DeadZone = DefaultConfig.HeadPointer.DeadZone
+	}
+
+	if config.HeadPointer.ClutchKey == nil {
+		config.HeadPointer.ClutchKey = DefaultConfig.HeadPointer.ClutchKey
+	}
+
+	if config.HeadPointer.ClutchButton == nil {
+		config.HeadPointer.ClutchButton = DefaultConfig.HeadPointer.ClutchButton
+	}
+
+	if config.Zoom.InKey == nil {
+		config.Zoom.InKey = DefaultConfig.Zoom.InKey
+	}
+
+	if config.Zoom.OutKey == nil {
+		config.Zoom.OutKey = DefaultConfig.Zoom.OutKey
+	}
+
+	if config.Zoom.PixelPerfectKey == nil {
+		config.Zoom.PixelPerfectKey = DefaultConfig.Zoom.PixelPerfectKey
+	}
+
+	if config.Zoom.Step == nil {
+		config.Zoom.Step = DefaultConfig.Zoom.Step
+	}
+
+	if config.Zoom.MaxMagnification == nil {
+		config.Zoom.MaxMagnification = DefaultConfig.Zoom.MaxMagnification
+	}
+
+	if config.Zoom.Easing == nil {
+		config.Zoom.Easing = DefaultConfig.Zoom.Easing
+	}
+
+	if config.Rendering.TextureFilter == nil {
+		config.Rendering.TextureFilter = DefaultConfig.Rendering.TextureFilter
+	}
+
+	if config.Rendering.Anisotropy == nil {
+		config.Rendering.Anisotropy = DefaultConfig.Rendering.Anisotropy
+	}
+
+	if config.Rendering.MSAA == nil {
+		config.Rendering.MSAA = DefaultConfig.Rendering.MSAA
+	}
+
+	if config.Rendering.Supersampling == nil {
+		config.Rendering.Supersampling = DefaultConfig.Rendering.Supersampling
+	}
+
+	if config.HUD.Enabled == nil {
+		config.HUD.Enabled = DefaultConfig.HUD.Enabled
+	}
+
+	if config.HUD.ToastDuration == nil {
+		config.HUD.ToastDuration = DefaultConfig.HUD.ToastDuration
+	}
+
+	if config.HUD.FadeDuration == nil {
+		config.HUD.FadeDuration = DefaultConfig.HUD.FadeDuration
+	}
+
+	if config.HUD.Widgets == nil {
+		config.HUD.Widgets = DefaultConfig.HUD.Widgets
+	}
+
+	if config.HUD.ShowWidgets == nil {
+		config.HUD.ShowWidgets = DefaultConfig.HUD.ShowWidgets
+	}
+
 	if config.Overrides.AllowUnsupportedDevices == nil {
 		config.Overrides.AllowUnsupportedDevices = DefaultConfig.Overrides.AllowUnsupportedDevices
 	}
@@ -85,4 +677,371 @@ func InitializePotentiallyMissingConfigValues(config *Config) {
 	if config.Overrides.OverrideRefreshRate == nil {
 		config.Overrides.OverrideRefreshRate = DefaultConfig.Overrides.OverrideRefreshRate
 	}
+
+	if config.Profile == nil {
+		config.Profile = DefaultConfig.Profile
+	}
+
+	if config.Profiles == nil {
+		config.Profiles = map[string]*ProfileConfig{
+			DefaultProfileName: {},
+		}
+	}
+
+	for name, profile := range config.Profiles {
+		// A profile without any settings in it
+		if profile == nil {
+			profile = &ProfileConfig{}
+			config.Profiles[name] = profile
+		}
+
+		initializeProfile(profile, config)
+	}
+
+	if config.Privileges.Mode == nil {
+		config.Privileges.Mode = DefaultConfig.Privileges.Mode
+	}
+
+	if config.Privileges.EscalationBackend == nil {
+		config.Privileges.EscalationBackend = DefaultConfig.Privileges.EscalationBackend
+	}
+
+	if config.Privileges.EnvironmentAllowlist == nil {
+		config.Privileges.EnvironmentAllowlist = DefaultConfig.Privileges.EnvironmentAllowlist
+	}
+}
+
+var environmentVariablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Keys that can be bound to actions: F1 to F12
+var keyBindingPattern = regexp.MustCompile(`^(none|f([1-9]|1[0-2]))$`)
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// Environment variables that may never be forwarded to an elevated process
+var forbiddenEnvironmentVariables = []string{
+	"LD_PRELOAD",
+	"LD_AUDIT",
+	"PATH",
+	"UXR_HAS_PRIVESC",
+	"UXR_CONFIG_ON_STDIN",
+	"UNREALXR_CONFIG_PATH",
+	"XDG_RUNTIME_DIR",
+}
+
+func isValidAnchoring(anchoring string) bool {
+	return anchoring == AnchoringWorld || anchoring == AnchoringHead || anchoring == AnchoringFollow
+}
+
+func validateAxisFilter(axis AxisFilterConfig) error {
+	switch *axis.Filter {
+	case FilterNone, FilterExponential, FilterOneEuro:
+	default:
+		return fmt.Errorf("unknown filter '%s'", *axis.Filter)
+	}
+
+	if *axis.MinCutoff <= 0 {
+		return fmt.Errorf("cutoff frequency must be positive, got %g", *axis.MinCutoff)
+	}
+
+	if *axis.Beta < 0 {
+		return fmt.Errorf("beta can't be negative")
+	}
+
+	return nil
+}
+
+func validateProfile(profile *ProfileConfig, config *Config) error {
+	if *profile.Stereo.IPD <= 0 || *profile.Stereo.IPD > 100 {
+		return fmt.Errorf("IPD must be between 0 and 100 millimeters, got %g", *profile.Stereo.IPD)
+	}
+
+	if *profile.Stereo.ConvergenceDistance <= 0 {
+		return fmt.Errorf("convergence distance must be positive, got %g", *profile.Stereo.ConvergenceDistance)
+	}
+
+	if *profile.Stereo.DisplayDistance <= 0 {
+		return fmt.Errorf("display distance must be positive, got %g", *profile.Stereo.DisplayDistance)
+	}
+
+	switch *profile.Layout.Type {
+	case layout.KindArc, layout.KindRow, layout.KindGrid, layout.KindStack, layout.KindCockpit:
+	default:
+		return fmt.Errorf("unknown layout '%s'", *profile.Layout.Type)
+	}
+
+	if *profile.Layout.Columns < 1 {
+		return fmt.Errorf("layout needs at least 1 column, got %d", *profile.Layout.Columns)
+	}
+
+	switch *profile.Background.Type {
+	case BackgroundSolid, BackgroundGrid, BackgroundRoom:
+	case BackgroundSkybox:
+		if *profile.Background.Image == "" {
+			return fmt.Errorf("skybox backgrounds need an image")
+		}
+	default:
+		return fmt.Errorf("unknown background '%s'", *profile.Background.Type)
+	}
+
+	if !hexColorPattern.MatchString(*profile.Background.Color) {
+		return fmt.Errorf("invalid background color '%s' (expected '#rrggbb')", *profile.Background.Color)
+	}
+
+	if *profile.Background.Opacity < 0 || *profile.Background.Opacity > 1 {
+		return fmt.Errorf("background opacity must be between 0 and 1, got %g", *profile.Background.Opacity)
+	}
+
+	if *profile.Background.Dim < 0 || *profile.Background.Dim > 1 {
+		return fmt.Errorf("background dim must be between 0 and 1, got %g", *profile.Background.Dim)
+	}
+
+	for blendState, dim := range profile.Background.BlendDim {
+		if _, ok := arcommons.ARBlendStateNames[blendState]; !ok {
+			return fmt.Errorf("unknown blend state '%s'", blendState)
+		}
+
+		if dim < 0 || dim > 1 {
+			return fmt.Errorf("background dim for blend state '%s' must be between 0 and 1, got %g", blendState, dim)
+		}
+	}
+
+	for display, pose := range profile.Layout.Displays {
+		if display < 0 || display >= *config.DisplayConfig.Count {
+			return fmt.Errorf("pose set for display #%d, but there are only %d displays", display, *config.DisplayConfig.Count)
+		}
+
+		if pose == nil {
+			continue
+		}
+
+		if pose.Position != nil && len(pose.Position) != 3 {
+			return fmt.Errorf("position of display #%d must have 3 values, got %d", display, len(pose.Position))
+		}
+
+		if pose.Rotation != nil && len(pose.Rotation) != 3 {
+			return fmt.Errorf("rotation of display #%d must have 3 values, got %d", display, len(pose.Rotation))
+		}
+
+		if pose.Scale != nil && *pose.Scale <= 0 {
+			return fmt.Errorf("scale of display #%d must be positive, got %g", display, *pose.Scale)
+		}
+	}
+
+	return nil
+}
+
+// Checks that a config (with missing values initialized) only contains sane values
+func Validate(config *Config) error {
+	if *config.DisplayConfig.Count < 1 || *config.DisplayConfig.Count > 16 {
+		return fmt.Errorf("display count must be between 1 and 16, got %d", *config.DisplayConfig.Count)
+	}
+
+	if *config.DisplayConfig.FOV < 1 || *config.DisplayConfig.FOV > 179 {
+		return fmt.Errorf("FOV must be between 1 and 179 degrees, got %d", *config.DisplayConfig.FOV)
+	}
+
+	switch *config.DisplayConfig.BlankedBehavior {
+	case BlankedBehaviorHidden, BlankedBehaviorDimmed, BlankedBehaviorPlaceholder:
+	default:
+		return fmt.Errorf("unknown blanked display behavior '%s'", *config.DisplayConfig.BlankedBehavior)
+	}
+
+	if *config.DisplayConfig.IdleFPS < 1 {
+		return fmt.Errorf("idle FPS must be at least 1, got %d", *config.DisplayConfig.IdleFPS)
+	}
+
+	if !isValidAnchoring(*config.DisplayConfig.Anchoring) {
+		return fmt.Errorf("unknown anchoring '%s'", *config.DisplayConfig.Anchoring)
+	}
+
+	for display, anchoring := range config.DisplayConfig.DisplayAnchoring {
+		if display < 0 || display >= *config.DisplayConfig.Count {
+			return fmt.Errorf("anchoring set for display #%d, but there are only %d displays", display, *config.DisplayConfig.Count)
+		}
+
+		if !isValidAnchoring(anchoring) {
+			return fmt.Errorf("unknown anchoring '%s' for display #%d", anchoring, display)
+		}
+	}
+
+	if *config.DisplayConfig.FollowDeadZone < 0 || *config.DisplayConfig.FollowDeadZone > 180 {
+		return fmt.Errorf("follow dead zone must be between 0 and 180 degrees, got %d", *config.DisplayConfig.FollowDeadZone)
+	}
+
+	if *config.DisplayConfig.FollowEasing < 0 {
+		return fmt.Errorf("follow easing can't be negative")
+	}
+
+	for display := range config.DisplayConfig.DisplayCurved {
+		if display < 0 || display >= *config.DisplayConfig.Count {
+			return fmt.Errorf("curvature set for display #%d, but there are only %d displays", display, *config.DisplayConfig.Count)
+		}
+	}
+
+	if *config.DisplayConfig.CurveRadius < 0 {
+		return fmt.Errorf("curve radius can't be negative")
+	}
+
+	if !keyBindingPattern.MatchString(*config.DisplayConfig.EditKey) {
+		return fmt.Errorf("unknown edit key '%s' (expected 'none' or 'f1' to 'f12')", *config.DisplayConfig.EditKey)
+	}
+
+	if *config.DisplayConfig.SnapAngle < 0 {
+		return fmt.Errorf("snap angle can't be negative")
+	}
+
+	if *config.HeadTracking.RecenterDuration < 0 {
+		return fmt.Errorf("recenter duration can't be negative")
+	}
+
+	if !keyBindingPattern.MatchString(*config.HeadTracking.RecenterKey) {
+		return fmt.Errorf("unknown recenter key '%s' (expected 'none' or 'f1' to 'f12')", *config.HeadTracking.RecenterKey)
+	}
+
+	if _, ok := arcommons.ARButtonNames[*config.HeadTracking.RecenterButton]; !ok && *config.HeadTracking.RecenterButton != BindingNone {
+		return fmt.Errorf("unknown recenter button '%s'", *config.HeadTracking.RecenterButton)
+	}
+
+	if *config.HeadTracking.AutoRecenterAngle < 1 || *config.HeadTracking.AutoRecenterAngle > 180 {
+		return fmt.Errorf("auto recenter angle must be between 1 and 180 degrees, got %d", *config.HeadTracking.AutoRecenterAngle)
+	}
+
+	if *config.HeadTracking.AutoRecenterDelay < 0 {
+		return fmt.Errorf("auto recenter delay can't be negative")
+	}
+
+	switch *config.HeadTracking.Prediction {
+	case PredictionOff, PredictionAuto, PredictionFixed:
+	default:
+		return fmt.Errorf("unknown prediction mode '%s'", *config.HeadTracking.Prediction)
+	}
+
+	if *config.HeadTracking.PredictionInterval < 0 || *config.HeadTracking.PredictionInterval > 50 {
+		return fmt.Errorf("prediction interval must be between 0 and 50 milliseconds, got %g", *config.HeadTracking.PredictionInterval)
+	}
+
+	axisFilters := map[string]AxisFilterConfig{
+		"yaw":   config.HeadTracking.Filter.Yaw,
+		"pitch": config.HeadTracking.Filter.Pitch,
+		"roll":  config.HeadTracking.Filter.Roll,
+	}
+
+	for axisName, axis := range axisFilters {
+		if err := validateAxisFilter(axis); err != nil {
+			return fmt.Errorf("invalid %s filter: %w", axisName, err)
+		}
+	}
+
+	if *config.Focus.DwellTime < 0 {
+		return fmt.Errorf("focus dwell time can't be negative")
+	}
+
+	if *config.Focus.PointerWarp != PointerWarpNone && *config.Focus.PointerWarp != PointerWarpUinput {
+		return fmt.Errorf("unknown pointer warp method '%s'", *config.Focus.PointerWarp)
+	}
+
+	if *config.HeadPointer.Gain <= 0 {
+		return fmt.Errorf("head pointer gain must be positive")
+	}
+
+	if *config.HeadPointer.DeadZone < 0 {
+		return fmt.Errorf("head pointer dead zone can't be negative")
+	}
+
+	if !keyBindingPattern.MatchString(*config.HeadPointer.ClutchKey) {
+		return fmt.Errorf("unknown clutch key '%s' (expected 'none' or 'f1' to 'f12')", *config.HeadPointer.ClutchKey)
+	}
+
+	if _, ok := arcommons.ARButtonNames[*config.HeadPointer.ClutchButton]; !ok && *config.HeadPointer.ClutchButton != BindingNone {
+		return fmt.Errorf("unknown clutch button '%s'", *config.HeadPointer.ClutchButton)
+	}
+
+	zoomKeys := map[string]*string{
+		"zoom in":       config.Zoom.InKey,
+		"zoom out":      config.Zoom.OutKey,
+		"pixel perfect": config.Zoom.PixelPerfectKey,
+	}
+
+	for action, key := range zoomKeys {
+		if !keyBindingPattern.MatchString(*key) {
+			return fmt.Errorf("unknown %s key '%s' (expected 'none' or 'f1' to 'f12')", action, *key)
+		}
+	}
+
+	if *config.Zoom.Step <= 1 {
+		return fmt.Errorf("zoom step must be greater than 1")
+	}
+
+	if *config.Zoom.MaxMagnification < 1 {
+		return fmt.Errorf("maximum magnification must be at least 1")
+	}
+
+	if *config.Zoom.Easing < 0 {
+		return fmt.Errorf("zoom easing can't be negative")
+	}
+
+	switch *config.Rendering.TextureFilter {
+	case TextureFilterPoint, TextureFilterBilinear, TextureFilterTrilinear:
+	default:
+		return fmt.Errorf("unknown texture filter '%s'", *config.Rendering.TextureFilter)
+	}
+
+	if !slices.Contains([]int{0, 4, 8, 16}, *config.Rendering.Anisotropy) {
+		return fmt.Errorf("anisotropy must be 0, 4, 8 or 16, got %d", *config.Rendering.Anisotropy)
+	}
+
+	if *config.Rendering.Supersampling < 1 || *config.Rendering.Supersampling > 2 {
+		return fmt.Errorf("supersampling must be between 1 and 2, got %g", *config.Rendering.Supersampling)
+	}
+
+	if *config.HUD.ToastDuration <= 0 {
+		return fmt.Errorf("HUD toast duration must be positive")
+	}
+
+	if *config.HUD.FadeDuration < 0 {
+		return fmt.Errorf("HUD fade duration can't be negative")
+	}
+
+	for _, widget := range config.HUD.Widgets {
+		switch widget {
+		case HUDWidgetClock, HUDWidgetFPS, HUDWidgetIMU, HUDWidgetBattery:
+		default:
+			return fmt.Errorf("unknown HUD widget '%s'", widget)
+		}
+	}
+
+	if *config.HUD.ShowWidgets != HUDShowAlways && *config.HUD.ShowWidgets != HUDShowLookDown {
+		return fmt.Errorf("unknown HUD widget visibility '%s'", *config.HUD.ShowWidgets)
+	}
+
+	if _, ok := config.Profiles[*config.Profile]; !ok {
+		return fmt.Errorf("profile '%s' doesn't exist", *config.Profile)
+	}
+
+	for name, profile := range config.Profiles {
+		if err := validateProfile(profile, config); err != nil {
+			return fmt.Errorf("invalid profile '%s': %w", name, err)
+		}
+	}
+
+	if *config.Privileges.Mode != PrivilegeModeHelper && *config.Privileges.Mode != PrivilegeModeLegacy {
+		return fmt.Errorf("unknown privilege mode '%s'", *config.Privileges.Mode)
+	}
+
+	if !slices.Contains(EscalationBackends, *config.Privileges.EscalationBackend) {
+		return fmt.Errorf("unknown escalation backend '%s'", *config.Privileges.EscalationBackend)
+	}
+
+	for _, variable := range config.Privileges.EnvironmentAllowlist {
+		if !environmentVariablePattern.MatchString(variable) {
+			return fmt.Errorf("invalid environment variable name '%s' in allowlist", variable)
+		}
+
+		if slices.Contains(forbiddenEnvironmentVariables, variable) {
+			return fmt.Errorf("environment variable '%s' can't be forwarded to elevated processes", variable)
+		}
+	}
+
+	return nil
 }
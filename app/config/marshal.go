@@ -0,0 +1,44 @@
+package config
+
+import (
+	"strconv"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/token"
+)
+
+// Unquotes map keys that are numbers
+type numberKeyUnquoter struct{}
+
+func (unquoter numberKeyUnquoter) Visit(node ast.Node) ast.Visitor {
+	mappingValue, ok := node.(*ast.MappingValueNode)
+
+	if !ok {
+		return unquoter
+	}
+
+	if key, ok := mappingValue.Key.(*ast.StringNode); ok {
+		unquoted, err := strconv.Unquote(key.Token.Value)
+
+		if _, isNumber := strconv.Atoi(unquoted); err == nil && isNumber == nil {
+			mappingValue.Key = ast.Integer(token.New(unquoted, unquoted, key.Token.Position))
+		}
+	}
+
+	return unquoter
+}
+
+// Serializes a value to YAML. Unlike yaml.Marshal, maps with number keys (like display numbers) are written so they load back into the same map
+func Marshal(value any) ([]byte, error) {
+	// The encoder quotes all map keys that look like numbers, as it turns every key into a string first
+	node, err := yaml.ValueToNode(value, yaml.IndentSequence(true))
+
+	if err != nil {
+		return nil, err
+	}
+
+	ast.Walk(numberKeyUnquoter{}, node)
+
+	return []byte(node.String() + "\n"), nil
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Loads config YAML the way the app does
+func loadTestConfig(t *testing.T, configBytes []byte) *Config {
+	t.Helper()
+
+	config := &Config{}
+
+	if err := yaml.Unmarshal(configBytes, config); err != nil {
+		t.Fatalf("failed to parse config: %s", err)
+	}
+
+	InitializePotentiallyMissingConfigValues(config)
+
+	if err := Validate(config); err != nil {
+		t.Fatalf("invalid config: %s", err)
+	}
+
+	return config
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	config := loadTestConfig(t, InitialConfig)
+	config.DisplayConfig.DisplayAnchoring = map[int]string{
+		0: AnchoringHead,
+		2: AnchoringFollow,
+	}
+
+	marshalled, err := Marshal(config)
+
+	if err != nil {
+		t.Fatalf("failed to marshal config: %s", err)
+	}
+
+	loaded := loadTestConfig(t, marshalled)
+
+	if !reflect.DeepEqual(config.DisplayConfig.DisplayAnchoring, loaded.DisplayConfig.DisplayAnchoring) {
+		t.Errorf("display anchoring changed after a round trip: %v, expected %v", loaded.DisplayConfig.DisplayAnchoring, config.DisplayConfig.DisplayAnchoring)
+	}
+
+	// Maps that were left out come back empty instead of nil, so compare what's written instead
+	remarshalled, err := Marshal(loaded)
+
+	if err != nil {
+		t.Fatalf("failed to marshal loaded config: %s", err)
+	}
+
+	if !bytes.Equal(marshalled, remarshalled) {
+		t.Errorf("config changed after a round trip. Before:\n%s\nAfter:\n%s", marshalled, remarshalled)
+	}
+}
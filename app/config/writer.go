@@ -0,0 +1,293 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+)
+
+// Returned when the config file can't be written, because we don't know where it is or we're running as root
+var ErrConfigNotWritable = errors.New("config file is not writable")
+
+// Checks if the config file in the config directory can be written to. As root, we never touch the user's files
+func IsWritable(configDir string) bool {
+	return configDir != "" && os.Geteuid() != 0
+}
+
+// Gets a YAML path from a list of keys, quoting keys where needed
+func pathFromKeys(keys []string) *yaml.Path {
+	builder := (&yaml.PathBuilder{}).Root()
+
+	for _, key := range keys {
+		builder = builder.Child(key)
+	}
+
+	return builder.Build()
+}
+
+// Creates YAML for a value nested below the given keys, as if it was written at the top level
+func nestedValue(keys []string, value any) ([]byte, error) {
+	for i := len(keys) - 1; i >= 0; i-- {
+		value = yaml.MapSlice{{Key: keys[i], Value: value}}
+	}
+
+	return Marshal(value)
+}
+
+// Sets a value in a parsed config file, creating missing parent keys. Everything else, including comments, is kept as is
+func setValue(file *ast.File, keys []string, value any) error {
+	if len(file.Docs) == 0 || file.Docs[0].Body == nil {
+		return fmt.Errorf("config file is empty")
+	}
+
+	// Find the deepest key that already has a value, so only the missing part is added to it
+	node := file.Docs[0].Body
+	existing := 0
+
+	for existing < len(keys) {
+		if node.Type() != ast.MappingType {
+			return fmt.Errorf("'%s' is not a mapping", strings.Join(keys[:existing], "."))
+		}
+
+		child, err := pathFromKeys(keys[:existing+1]).FilterFile(file)
+
+		if errors.Is(err, yaml.ErrNotFoundNode) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to look up '%s': %w", strings.Join(keys[:existing+1], "."), err)
+		}
+
+		node = child
+		existing++
+
+		if node.Type() == ast.NullType {
+			break
+		}
+	}
+
+	// A key that's left empty is set from its parent, as that keeps the indentation right
+	if node.Type() == ast.NullType {
+		existing--
+	}
+
+	keyPath := pathFromKeys(keys[:existing])
+	missing, err := nestedValue(keys[existing:], value)
+
+	if err != nil {
+		return fmt.Errorf("failed to serialize value: %w", err)
+	}
+
+	if existing == len(keys) {
+		return replaceValue(file, keyPath, node, missing)
+	}
+
+	return keyPath.MergeFromReader(file, strings.NewReader(string(missing)))
+}
+
+// Replaces an existing value, keeping the comment after it if the new value is a scalar too
+func replaceValue(file *ast.File, keyPath *yaml.Path, existingNode ast.Node, value []byte) error {
+	comment := existingNode.GetComment()
+
+	if err := keyPath.ReplaceWithReader(file, strings.NewReader(string(value))); err != nil {
+		return err
+	}
+
+	if comment == nil {
+		return nil
+	}
+
+	replacedNode, err := keyPath.FilterFile(file)
+
+	if err != nil {
+		return err
+	}
+
+	if _, ok := replacedNode.(ast.ScalarNode); ok {
+		return replacedNode.SetComment(comment)
+	}
+
+	return nil
+}
+
+// Finds flow style collections (like `[]` or `{}`) in a parsed file, by path
+type flowCollectionFinder struct {
+	// Line and column of the closing bracket, by path
+	ends map[string]*token.Position
+}
+
+func (finder flowCollectionFinder) Visit(node ast.Node) ast.Visitor {
+	switch collection := node.(type) {
+	case *ast.SequenceNode:
+		if collection.IsFlowStyle && collection.End != nil {
+			finder.ends[collection.GetPath()] = collection.End.Position
+		}
+
+	case *ast.MappingNode:
+		if collection.IsFlowStyle && collection.End != nil {
+			finder.ends[collection.GetPath()] = collection.End.Position
+		}
+	}
+
+	return finder
+}
+
+// Finds the closing brackets of all flow style collections in a parsed file, by path
+func flowCollectionEnds(file *ast.File) map[string]*token.Position {
+	finder := flowCollectionFinder{
+		ends: map[string]*token.Position{},
+	}
+
+	for _, doc := range file.Docs {
+		ast.Walk(finder, doc)
+	}
+
+	return finder.ends
+}
+
+// Gets the comments that follow flow style collections on the same line (like `widgets: [] # ...`), by path.
+//
+// The parser drops these, so they have to be put back with restoreFlowCollectionComments.
+func flowCollectionComments(source string, file *ast.File) map[string]string {
+	lines := strings.Split(source, "\n")
+	comments := map[string]string{}
+
+	for collectionPath, end := range flowCollectionEnds(file) {
+		if end.Line < 1 || end.Line > len(lines) {
+			continue
+		}
+
+		// Columns count characters, starting at 1
+		line := []rune(lines[end.Line-1])
+
+		if end.Column < 1 || end.Column > len(line) {
+			continue
+		}
+
+		rest := strings.TrimLeft(string(line[end.Column:]), " \t")
+
+		if strings.HasPrefix(rest, "#") {
+			comments[collectionPath] = rest
+		}
+	}
+
+	return comments
+}
+
+// Puts comments collected by flowCollectionComments back after the flow style collections at the same paths
+func restoreFlowCollectionComments(source string, comments map[string]string) (string, error) {
+	if len(comments) == 0 {
+		return source, nil
+	}
+
+	file, err := parser.ParseBytes([]byte(source), 0)
+
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(source, "\n")
+
+	for collectionPath, end := range flowCollectionEnds(file) {
+		comment, ok := comments[collectionPath]
+
+		if !ok || end.Line < 1 || end.Line > len(lines) {
+			continue
+		}
+
+		lines[end.Line-1] += " " + comment
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// Sets a value in the given config file contents, creating missing parent keys. Comments and formatting of the rest of the file are kept
+func updateConfig(configBytes []byte, keys []string, value any) ([]byte, error) {
+	file, err := parser.ParseBytes(configBytes, parser.ParseComments)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	comments := flowCollectionComments(string(configBytes), file)
+
+	if err := setValue(file, keys, value); err != nil {
+		return nil, fmt.Errorf("failed to update config file: %w", err)
+	}
+
+	updated, err := restoreFlowCollectionComments(file.String(), comments)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore comments: %w", err)
+	}
+
+	return []byte(updated), nil
+}
+
+// Sets a value in config.yml in the config directory, creating missing parent keys. Comments and formatting of the rest of the file are kept
+func WriteValue(configDir string, keys []string, value any) error {
+	if !IsWritable(configDir) {
+		return ErrConfigNotWritable
+	}
+
+	configPath := path.Join(configDir, "config.yml")
+	configFile, err := os.OpenFile(configPath, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+
+	configBytes, err := io.ReadAll(configFile)
+	configFile.Close()
+
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	updatedConfig, err := updateConfig(configBytes, keys, value)
+
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so a crash can't leave a half written config behind
+	temporaryFile, err := os.CreateTemp(configDir, ".config.yml.*")
+
+	if err != nil {
+		return fmt.Errorf("failed to create temporary config file: %w", err)
+	}
+
+	defer os.Remove(temporaryFile.Name())
+
+	if _, err := temporaryFile.Write(updatedConfig); err != nil {
+		temporaryFile.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	if err := temporaryFile.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	if err := os.Chmod(temporaryFile.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	if err := os.Rename(temporaryFile.Name(), configPath); err != nil {
+		return fmt.Errorf("failed to replace config file: %w", err)
+	}
+
+	return nil
+}
+
+// Saves the poses of single displays into the layout of a profile in config.yml
+func WriteDisplayPoses(configDir, profile string, displays map[int]*DisplayPoseConfig) error {
+	return WriteValue(configDir, []string{"profiles", profile, "layout", "displays"}, displays)
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpdateConfigKeepsComments(t *testing.T) {
+	scale := float32(1.5)
+	poses := map[int]*DisplayPoseConfig{
+		1: {
+			Position: []float32{0.5, 1, -2},
+			Rotation: []float32{10, 0, 0},
+			Scale:    &scale,
+		},
+	}
+
+	updated, err := updateConfig(InitialConfig, []string{"profiles", DefaultProfileName, "layout", "displays"}, poses)
+
+	if err != nil {
+		t.Fatalf("failed to update config: %s", err)
+	}
+
+	for _, line := range strings.Split(string(InitialConfig), "\n") {
+		commentStart := strings.Index(line, "#")
+
+		if commentStart == -1 {
+			continue
+		}
+
+		if comment := strings.TrimSpace(line[commentStart:]); !strings.Contains(string(updated), comment) {
+			t.Errorf("lost comment '%s'", comment)
+		}
+	}
+
+	config := loadTestConfig(t, updated)
+	pose := config.Profiles[DefaultProfileName].Layout.Displays[1]
+
+	if pose == nil {
+		t.Fatalf("pose of display 1 is missing from the updated config:\n%s", updated)
+	}
+
+	if len(pose.Position) != 3 || pose.Position[0] != 0.5 || pose.Position[1] != 1 || pose.Position[2] != -2 {
+		t.Errorf("position is %v, expected [0.5 1 -2]", pose.Position)
+	}
+
+	if len(pose.Rotation) != 3 || pose.Rotation[0] != 10 {
+		t.Errorf("rotation is %v, expected [10 0 0]", pose.Rotation)
+	}
+
+	if pose.Scale == nil || *pose.Scale != scale {
+		t.Errorf("scale is %v, expected %g", pose.Scale, scale)
+	}
+}
+
+func TestUpdateConfigKeepsFlowCollectionComments(t *testing.T) {
+	source := "hud:\n  widgets: [clock] # Widgets\n  enabled: true # Enabled\nextra: {} # Extra\n"
+
+	updated, err := updateConfig([]byte(source), []string{"hud", "enabled"}, false)
+
+	if err != nil {
+		t.Fatalf("failed to update config: %s", err)
+	}
+
+	expected := "hud:\n  widgets: [clock] # Widgets\n  enabled: false # Enabled\nextra: {} # Extra\n"
+
+	if string(updated) != expected {
+		t.Errorf("updated config is:\n%s\nexpected:\n%s", updated, expected)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/edidtools"
+	"git.lunr.sh/UnrealXR/unrealxr/edidpatcher"
+	"github.com/charmbracelet/log"
+	"github.com/urfave/cli/v3"
+)
+
+func edidVerifyEntrypoint(_ context.Context, cmd *cli.Command) error {
+	var (
+		originalEDID []byte
+		patchedEDID  []byte
+		err          error
+	)
+
+	switch cmd.Args().Len() {
+	case 0:
+		log.Info("Fetching EDID from connected XR device")
+		displayMetadata, err := edidtools.FetchXRGlassEDID(cmd.Bool("allow-unsupported-devices"))
+
+		if err != nil {
+			return fmt.Errorf("failed to fetch EDID: %w", err)
+		}
+
+		originalEDID = displayMetadata.EDID
+
+	case 1, 2:
+		originalEDID, err = os.ReadFile(cmd.Args().Get(0))
+
+		if err != nil {
+			return fmt.Errorf("failed to read original EDID file: %w", err)
+		}
+
+	default:
+		return fmt.Errorf("expected at most 2 arguments, got %d", cmd.Args().Len())
+	}
+
+	if cmd.Args().Len() == 2 {
+		patchedEDID, err = os.ReadFile(cmd.Args().Get(1))
+
+		if err != nil {
+			return fmt.Errorf("failed to read patched EDID file: %w", err)
+		}
+	} else {
+		log.Debug("Patching EDID firmware to be specialized")
+		patchedEDID, err = edidpatcher.PatchEDIDToBeSpecialized(originalEDID)
+
+		if err != nil {
+			return fmt.Errorf("failed to patch EDID firmware: %w", err)
+		}
+	}
+
+	if err := edidpatcher.Verify(originalEDID, patchedEDID); err != nil {
+		return fmt.Errorf("patched EDID failed verification:\n%w", err)
+	}
+
+	log.Info("Patched EDID passed verification")
+	return nil
+}
+
+var edidCommand = &cli.Command{
+	Name:  "edid",
+	Usage: "Inspect and verify EDID firmware",
+	Commands: []*cli.Command{
+		{
+			Name:      "verify",
+			Usage:     "Verify that a patched EDID is valid and preserves the original timings",
+			ArgsUsage: "[original EDID file] [patched EDID file]",
+			Description: "With no arguments, the EDID of the connected XR device is patched and verified.\n" +
+				"With one argument, the given EDID file is patched and verified.\n" +
+				"With two arguments, an already patched EDID file is verified against the original.",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "allow-unsupported-devices",
+					Usage: "allow unsupported devices as long as they're a compatible vendor",
+				},
+			},
+			Action: edidVerifyEntrypoint,
+		},
+	},
+}
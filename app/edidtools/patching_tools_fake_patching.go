@@ -7,6 +7,8 @@ import (
 	_ "embed"
 	"fmt"
 
+	"git.lunr.sh/UnrealXR/unrealxr/app/privhelper"
+	"git.lunr.sh/UnrealXR/unrealxr/edidpatcher"
 	"github.com/charmbracelet/log"
 )
 
@@ -30,13 +32,17 @@ func FetchXRGlassEDID(allowUnsupportedDevices bool) (*DisplayMetadata, error) {
 }
 
 // Loads custom firmware for a supported XR glass device
-func LoadCustomEDIDFirmware(displayMetadata *DisplayMetadata, edidFirmware []byte) error {
+func LoadCustomEDIDFirmware(ops privhelper.Operations, displayMetadata *DisplayMetadata, edidFirmware []byte) error {
+	if err := edidpatcher.Verify(displayMetadata.EDID, edidFirmware); err != nil {
+		return fmt.Errorf("refusing to load invalid EDID firmware: %w", err)
+	}
+
 	log.Warn("Not actually patching EDID firmware in fake patching build -- ignoring")
 	return nil
 }
 
 // Unloads custom firmware for a supported XR glass device
-func UnloadCustomEDIDFirmware(displayMetadata *DisplayMetadata) error {
+func UnloadCustomEDIDFirmware(ops privhelper.Operations, displayMetadata *DisplayMetadata) error {
 	log.Warn("Not actually unloading EDID firmware in fake patching build -- ignoring")
 	return nil
 }
@@ -9,6 +9,8 @@ import (
 	"os/exec"
 	"strings"
 
+	"git.lunr.sh/UnrealXR/unrealxr/app/privhelper"
+	"git.lunr.sh/UnrealXR/unrealxr/edidpatcher"
 	"github.com/charmbracelet/log"
 )
 
@@ -85,43 +87,23 @@ func FetchXRGlassEDID(allowUnsupportedDevices bool) (*DisplayMetadata, error) {
 }
 
 // Loads custom firmware for a supported XR glass device
-func LoadCustomEDIDFirmware(displayMetadata *DisplayMetadata, edidFirmware []byte) error {
+func LoadCustomEDIDFirmware(ops privhelper.Operations, displayMetadata *DisplayMetadata, edidFirmware []byte) error {
 	if displayMetadata.LinuxDRMCard == "" || displayMetadata.LinuxDRMConnector == "" {
 		return fmt.Errorf("missing Linux DRM card or connector information")
 	}
 
-	drmFile, err := os.OpenFile("/sys/kernel/debug/dri/"+strings.Replace(displayMetadata.LinuxDRMCard, "card", "", 1)+"/"+displayMetadata.LinuxDRMConnector+"/edid_override", os.O_WRONLY, 0644)
-
-	if err != nil {
-		return fmt.Errorf("failed to open EDID override file for monitor '%s': %w", displayMetadata.LinuxDRMConnector, err)
-	}
-
-	defer drmFile.Close()
-
-	if _, err := drmFile.Write(edidFirmware); err != nil {
-		return fmt.Errorf("failed to write EDID firmware for monitor '%s': %w", displayMetadata.LinuxDRMConnector, err)
+	if err := edidpatcher.Verify(displayMetadata.EDID, edidFirmware); err != nil {
+		return fmt.Errorf("refusing to load invalid EDID firmware for monitor '%s': %w", displayMetadata.LinuxDRMConnector, err)
 	}
 
-	return nil
+	return ops.WriteEDIDOverride(displayMetadata.LinuxDRMCard, displayMetadata.LinuxDRMConnector, edidFirmware)
 }
 
 // Unloads custom firmware for a supported XR glass device
-func UnloadCustomEDIDFirmware(displayMetadata *DisplayMetadata) error {
+func UnloadCustomEDIDFirmware(ops privhelper.Operations, displayMetadata *DisplayMetadata) error {
 	if displayMetadata.LinuxDRMCard == "" || displayMetadata.LinuxDRMConnector == "" {
 		return fmt.Errorf("missing Linux DRM card or connector information")
 	}
 
-	drmFile, err := os.OpenFile("/sys/kernel/debug/dri/"+strings.Replace(displayMetadata.LinuxDRMCard, "card", "", 1)+"/"+displayMetadata.LinuxDRMConnector+"/edid_override", os.O_WRONLY, 0644)
-
-	if err != nil {
-		return fmt.Errorf("failed to open EDID override file for monitor '%s': %w", displayMetadata.LinuxDRMConnector, err)
-	}
-
-	defer drmFile.Close()
-
-	if _, err := drmFile.Write([]byte("reset")); err != nil {
-		return fmt.Errorf("failed to unload EDID firmware for monitor '%s': %w", displayMetadata.LinuxDRMConnector, err)
-	}
-
-	return nil
+	return ops.ResetEDIDOverride(displayMetadata.LinuxDRMCard, displayMetadata.LinuxDRMConnector)
 }
@@ -3,7 +3,11 @@
 
 package edidtools
 
-import "fmt"
+import (
+	"fmt"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/privhelper"
+)
 
 // Attempts to fetch the EDID firmware for any supported XR glasses device
 func FetchXRGlassEDID(allowUnsupportedDevices bool) (*DisplayMetadata, error) {
@@ -11,11 +15,11 @@ func FetchXRGlassEDID(allowUnsupportedDevices bool) (*DisplayMetadata, error) {
 }
 
 // Loads custom firmware for a supported XR glass device
-func LoadCustomEDIDFirmware(displayMetadata *DisplayMetadata, edidFirmware []byte) error {
+func LoadCustomEDIDFirmware(ops privhelper.Operations, displayMetadata *DisplayMetadata, edidFirmware []byte) error {
 	return fmt.Errorf("loading custom EDID firmware is not supported on macOS")
 }
 
 // Unloads custom firmware for a supported XR glass device
-func UnloadCustomEDIDFirmware(displayMetadata *DisplayMetadata) error {
+func UnloadCustomEDIDFirmware(ops privhelper.Operations, displayMetadata *DisplayMetadata) error {
 	return fmt.Errorf("unloading custom EDID firmware is not supported on macOS")
 }
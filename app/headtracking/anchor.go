@@ -0,0 +1,84 @@
+package headtracking
+
+import (
+	"math"
+	"time"
+)
+
+// How close a following layout needs to get to the head before it stops catching up, in radians
+const followSettleAngle = 0.5 * math.Pi / 180
+
+// Decides how displays move along with the head.
+type AnchorMode int
+
+const (
+	// Displays stay in place
+	AnchorWorld AnchorMode = iota
+	// Displays stay fixed in view
+	AnchorHead
+	// Displays stay in place until the head turns far enough away, then catch up to it
+	AnchorFollow
+)
+
+// Keeps track of the rotation of a layout around the viewer for an anchor mode.
+type Anchor struct {
+	mode AnchorMode
+	// How far the head can turn away before a following layout catches up, in radians
+	deadZone float32
+	// Time constant of the catch up easing. 0 snaps the layout into place
+	easing time.Duration
+
+	rotation   Quaternion
+	isCatching bool
+}
+
+func NewAnchor(mode AnchorMode, deadZoneDegrees float32, easing time.Duration) *Anchor {
+	return &Anchor{
+		mode:     mode,
+		deadZone: deadZoneDegrees * math.Pi / 180,
+		easing:   easing,
+		rotation: IdentityQuaternion,
+	}
+}
+
+// Gets the mode the anchor was created with.
+func (anchor *Anchor) Mode() AnchorMode {
+	return anchor.mode
+}
+
+// Updates the anchor with the current head orientation, as used for the camera, and the time since the last update.
+// Returns the rotation to apply to the layout around the viewer.
+func (anchor *Anchor) Update(orientation Quaternion, elapsed time.Duration) Quaternion {
+	switch anchor.mode {
+	case AnchorHead:
+		anchor.rotation = orientation
+
+	case AnchorFollow:
+		// Roll is left out, so a following layout doesn't tilt when the head does
+		target := orientation.WithoutRoll()
+		angle := AngleBetween(anchor.rotation, target)
+
+		if angle > anchor.deadZone {
+			anchor.isCatching = true
+		}
+
+		if !anchor.isCatching {
+			break
+		}
+
+		if anchor.easing <= 0 || angle < followSettleAngle {
+			anchor.rotation = target
+			anchor.isCatching = false
+			break
+		}
+
+		// Exponential easing, so the layout moves quickly at first and slows down as it arrives, independent of the frame rate
+		t := 1 - float32(math.Exp(-float64(elapsed)/float64(anchor.easing)))
+		anchor.rotation = Slerp(anchor.rotation, target, t)
+
+	default:
+		anchor.rotation = IdentityQuaternion
+	}
+
+	return anchor.rotation
+}
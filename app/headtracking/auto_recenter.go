@@ -0,0 +1,47 @@
+package headtracking
+
+import (
+	"math"
+	"time"
+)
+
+// Decides when to recenter automatically, which is once the user has been facing away from the displays for long enough.
+type AutoRecenter struct {
+	// How far the user needs to face away from the displays, in radians
+	angle float32
+	// How long the user needs to face away from the displays
+	delay time.Duration
+
+	facingAwaySince time.Time
+	isFacingAway    bool
+}
+
+func NewAutoRecenter(angleDegrees float32, delay time.Duration) *AutoRecenter {
+	return &AutoRecenter{
+		angle: angleDegrees * math.Pi / 180,
+		delay: delay,
+	}
+}
+
+// Checks an orientation relative to the reference. Returns true if it's time to recenter.
+func (autoRecenter *AutoRecenter) Update(orientation Quaternion, now time.Time) bool {
+	yaw := orientation.Yaw()
+
+	if float32(math.Abs(float64(yaw))) < autoRecenter.angle {
+		autoRecenter.isFacingAway = false
+		return false
+	}
+
+	if !autoRecenter.isFacingAway {
+		autoRecenter.isFacingAway = true
+		autoRecenter.facingAwaySince = now
+		return false
+	}
+
+	if now.Sub(autoRecenter.facingAwaySince) < autoRecenter.delay {
+		return false
+	}
+
+	autoRecenter.isFacingAway = false
+	return true
+}
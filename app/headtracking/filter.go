@@ -0,0 +1,155 @@
+package headtracking
+
+import (
+	"math"
+	"time"
+)
+
+// Cutoff frequency used to smooth the speed One Euro filters adapt to, in Hz
+const oneEuroDerivativeCutoff = 1.0
+
+// Filters a single angle over time.
+type AngleFilter interface {
+	// Filters an angle in radians, given the time since the previous angle. Angles are continuous, they never wrap around.
+	Filter(angle float64, elapsed time.Duration) float64
+}
+
+// Gets the weight of a new value in a low pass filter with the given cutoff frequency
+func smoothingFactor(cutoff float64, elapsed time.Duration) float64 {
+	timeConstant := 1 / (2 * math.Pi * cutoff)
+	return 1 / (1 + timeConstant/elapsed.Seconds())
+}
+
+// Leaves angles as they are.
+type PassthroughFilter struct{}
+
+func (filter *PassthroughFilter) Filter(angle float64, elapsed time.Duration) float64 {
+	return angle
+}
+
+// Exponential smoothing, which is a low pass filter with a fixed cutoff frequency.
+type ExponentialFilter struct {
+	cutoff      float64
+	value       float64
+	initialized bool
+}
+
+func NewExponentialFilter(cutoff float64) *ExponentialFilter {
+	return &ExponentialFilter{
+		cutoff: cutoff,
+	}
+}
+
+func (filter *ExponentialFilter) Filter(angle float64, elapsed time.Duration) float64 {
+	if !filter.initialized {
+		filter.value = angle
+		filter.initialized = true
+		return angle
+	}
+
+	if elapsed <= 0 {
+		return filter.value
+	}
+
+	filter.value += (angle - filter.value) * smoothingFactor(filter.cutoff, elapsed)
+	return filter.value
+}
+
+// One Euro filter (Casiez et al.), a low pass filter whose cutoff frequency rises with speed.
+// It smooths heavily while the head is still, and hardly adds any lag while it's turning.
+type OneEuroFilter struct {
+	// Cutoff frequency while still, in Hz
+	minCutoff float64
+	// How much the cutoff frequency rises per radian per second
+	beta float64
+
+	value       float64
+	derivative  float64
+	initialized bool
+}
+
+func NewOneEuroFilter(minCutoff, beta float64) *OneEuroFilter {
+	return &OneEuroFilter{
+		minCutoff: minCutoff,
+		beta:      beta,
+	}
+}
+
+func (filter *OneEuroFilter) Filter(angle float64, elapsed time.Duration) float64 {
+	if !filter.initialized {
+		filter.value = angle
+		filter.initialized = true
+		return angle
+	}
+
+	if elapsed <= 0 {
+		return filter.value
+	}
+
+	derivative := (angle - filter.value) / elapsed.Seconds()
+	filter.derivative += (derivative - filter.derivative) * smoothingFactor(oneEuroDerivativeCutoff, elapsed)
+
+	cutoff := filter.minCutoff + filter.beta*math.Abs(filter.derivative)
+	filter.value += (angle - filter.value) * smoothingFactor(cutoff, elapsed)
+
+	return filter.value
+}
+
+// Wraps an angle to between -pi and pi
+func wrapAngle(angle float64) float64 {
+	return math.Remainder(angle, 2*math.Pi)
+}
+
+// Filters orientations by filtering their yaw, pitch and roll separately.
+// Filters only work on sensor timestamps, so recorded sensor streams can be fed through them just like live ones.
+type OrientationFilter struct {
+	// Filters for yaw, pitch and roll
+	axes [3]AngleFilter
+
+	// Last unfiltered angles, without wrapping around
+	angles        [3]float64
+	lastTimestamp uint64
+	hasLast       bool
+}
+
+func NewOrientationFilter(yaw, pitch, roll AngleFilter) *OrientationFilter {
+	return &OrientationFilter{
+		axes: [3]AngleFilter{yaw, pitch, roll},
+	}
+}
+
+// Filters an orientation with its sensor timestamp in nanoseconds.
+func (filter *OrientationFilter) Filter(orientation Quaternion, timestamp uint64) Quaternion {
+	angles := [3]float64{
+		float64(orientation.Yaw()),
+		float64(orientation.Pitch()),
+		float64(orientation.Roll()),
+	}
+
+	elapsed := time.Duration(0)
+
+	if filter.hasLast && timestamp > filter.lastTimestamp {
+		elapsed = time.Duration(timestamp - filter.lastTimestamp)
+	}
+
+	for axis, angle := range angles {
+		// Keep angles continuous, so turning past 180 degrees doesn't look like a jump to the filter
+		if filter.hasLast {
+			angle = filter.angles[axis] + wrapAngle(angle-wrapAngle(filter.angles[axis]))
+		}
+
+		filter.angles[axis] = angle
+	}
+
+	if !filter.hasLast || timestamp > filter.lastTimestamp {
+		filter.lastTimestamp = timestamp
+	}
+
+	filter.hasLast = true
+
+	yaw := filter.axes[0].Filter(filter.angles[0], elapsed)
+	pitch := filter.axes[1].Filter(filter.angles[1], elapsed)
+	roll := filter.axes[2].Filter(filter.angles[2], elapsed)
+
+	return QuaternionFromAngles(float32(wrapAngle(yaw)), float32(pitch), float32(wrapAngle(roll)))
+}
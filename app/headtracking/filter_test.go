@@ -0,0 +1,120 @@
+package headtracking
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// Sensor rate the filters are fed at
+const testSampleInterval = time.Millisecond
+
+// Peak noise added to the test angles, in radians
+const testNoise = 0.005
+
+// Feeds the filter two seconds of a head turning at a constant rate with noise on top, and measures the second half once the filter has settled.
+// Returns the lag behind the noiseless angle in time (or the offset in radians if the head is still) and the standard deviation of the filtered angle
+// around it, relative to the one of the noise.
+func measureFilter(filter AngleFilter, rate float64) (float64, float64) {
+	random := rand.New(rand.NewSource(1))
+	samples := int(2 * time.Second / testSampleInterval)
+
+	var errorSum, errorSquaredSum float64
+	measured := 0
+
+	for sample := range samples {
+		angle := rate * (time.Duration(sample) * testSampleInterval).Seconds()
+		filtered := filter.Filter(angle+(random.Float64()*2-1)*testNoise, testSampleInterval)
+
+		if sample < samples/2 {
+			continue
+		}
+
+		errorSum += angle - filtered
+		errorSquaredSum += (angle - filtered) * (angle - filtered)
+		measured++
+	}
+
+	meanError := errorSum / float64(measured)
+	jitter := math.Sqrt(errorSquaredSum/float64(measured)-meanError*meanError) / (testNoise / math.Sqrt(3))
+
+	if rate == 0 {
+		return meanError, jitter
+	}
+
+	return meanError / rate, jitter
+}
+
+func TestAngleFilterLagAndJitter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter func() AngleFilter
+		// Turning rate in radians per second
+		rate float64
+		// Lag in seconds, or offset in radians while still
+		minLag float64
+		maxLag float64
+		// Jitter relative to the noise
+		maxJitter float64
+	}{
+		{"passthrough still", func() AngleFilter { return &PassthroughFilter{} }, 0, -0.001, 0.001, 1.01},
+		{"passthrough turning", func() AngleFilter { return &PassthroughFilter{} }, 2, -0.001, 0.001, 1.01},
+		{"exponential still", func() AngleFilter { return NewExponentialFilter(1) }, 0, -0.001, 0.001, 0.1},
+		// Exponential smoothing lags by its time constant, 1 / (2 pi cutoff)
+		{"exponential turning", func() AngleFilter { return NewExponentialFilter(1) }, 2, 0.150, 0.170, 0.15},
+		{"exponential high cutoff turning", func() AngleFilter { return NewExponentialFilter(10) }, 2, 0.014, 0.018, 0.25},
+		{"one euro still", func() AngleFilter { return NewOneEuroFilter(1, 0.5) }, 0, -0.001, 0.001, 0.1},
+		{"one euro turning slowly", func() AngleFilter { return NewOneEuroFilter(1, 0.5) }, 0.5, 0, 0.030, 0.25},
+		{"one euro turning", func() AngleFilter { return NewOneEuroFilter(1, 0.5) }, 2, 0, 0.015, 0.3},
+		{"one euro turning fast", func() AngleFilter { return NewOneEuroFilter(1, 0.5) }, 4, 0, 0.010, 0.35},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lag, jitter := measureFilter(test.filter(), test.rate)
+
+			if lag < test.minLag || lag > test.maxLag {
+				t.Errorf("lag is %g, expected between %g and %g", lag, test.minLag, test.maxLag)
+			}
+
+			if jitter > test.maxJitter {
+				t.Errorf("jitter is %g of the noise, expected at most %g", jitter, test.maxJitter)
+			}
+		})
+	}
+}
+
+func TestAngleFilterHoldsWithoutElapsedTime(t *testing.T) {
+	filters := map[string]AngleFilter{
+		"exponential": NewExponentialFilter(1),
+		"one euro":    NewOneEuroFilter(1, 0.5),
+	}
+
+	for name, filter := range filters {
+		if filtered := filter.Filter(0.5, 0); filtered != 0.5 {
+			t.Errorf("%s: first angle was filtered to %g", name, filtered)
+		}
+
+		if filtered := filter.Filter(1, 0); filtered != 0.5 {
+			t.Errorf("%s: angle without elapsed time changed the output to %g", name, filtered)
+		}
+	}
+}
+
+func TestOrientationFilterTurnsPastHalfCircle(t *testing.T) {
+	filter := NewOrientationFilter(NewExponentialFilter(10), &PassthroughFilter{}, &PassthroughFilter{})
+	timestamp := uint64(0)
+
+	// Turn left from 170 to 190 degrees, where the yaw wraps around from pi to -pi
+	for yaw := 170.0; yaw <= 190; yaw += 0.1 {
+		orientation := QuaternionFromAngles(float32(yaw*math.Pi/180), 0, 0)
+		filtered := filter.Filter(orientation, timestamp)
+		timestamp += uint64(testSampleInterval)
+
+		// Filtering across the wrap as a jump of almost a full turn would swing the view the other way around
+		if angle := AngleBetween(orientation, filtered); angle > 0.05 {
+			t.Fatalf("filtered orientation is %g radians off at %g degrees", angle, yaw)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package headtracking
+
+import "math"
+
+// Turns head motion into pointer motion. Motion within the dead zone is taken up before the pointer moves, so trembling and drift don't move it, while slow deliberate motion still does.
+type HeadPointer struct {
+	// In radians
+	deadZone float32
+	// Direction the pointer follows, which trails the head by at most the dead zone
+	yaw   float32
+	pitch float32
+}
+
+func NewHeadPointer(deadZoneDegrees float32) *HeadPointer {
+	return &HeadPointer{
+		deadZone: deadZoneDegrees * math.Pi / 180,
+	}
+}
+
+// Starts following the head from an orientation, without moving the pointer.
+func (pointer *HeadPointer) Reset(orientation Quaternion) {
+	pointer.yaw = orientation.Yaw()
+	pointer.pitch = orientation.Pitch()
+}
+
+// Moves a trailing angle towards another one until it's at most deadZone away. Returns how far it moved
+func takeUpDeadZone(trailing *float32, angle, deadZone float32) float32 {
+	difference := float32(math.Remainder(float64(angle-*trailing), 2*math.Pi))
+
+	if difference > deadZone {
+		difference -= deadZone
+	} else if difference < -deadZone {
+		difference += deadZone
+	} else {
+		return 0
+	}
+
+	*trailing += difference
+
+	return difference
+}
+
+// Checks an orientation relative to the reference. Returns how far the pointer should move to the right and down, in degrees.
+func (pointer *HeadPointer) Update(orientation Quaternion) (right, down float32) {
+	// Positive yaw turns left and positive pitch looks up, so both are the other way around on the screen
+	left := takeUpDeadZone(&pointer.yaw, orientation.Yaw(), pointer.deadZone)
+	up := takeUpDeadZone(&pointer.pitch, orientation.Pitch(), pointer.deadZone)
+
+	return -left * 180 / math.Pi, -up * 180 / math.Pi
+}
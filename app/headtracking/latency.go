@@ -0,0 +1,40 @@
+package headtracking
+
+import (
+	"time"
+)
+
+// Time constant of the smoothing applied to frame timings
+const latencySmoothing = 500 * time.Millisecond
+
+// Estimates how long it takes from sampling the orientation until the frame using it is on screen, from frame timing.
+type LatencyEstimator struct {
+	renderTime time.Duration
+	frameTime  time.Duration
+	hasSamples bool
+}
+
+func NewLatencyEstimator() *LatencyEstimator {
+	return &LatencyEstimator{}
+}
+
+// Records the time from sampling the orientation until the frame was presented, and the time since the previous frame.
+func (estimator *LatencyEstimator) Record(renderTime, frameTime time.Duration) {
+	if !estimator.hasSamples {
+		estimator.renderTime = renderTime
+		estimator.frameTime = frameTime
+		estimator.hasSamples = true
+		return
+	}
+
+	// Weighted by the frame time, so the smoothing doesn't depend on the frame rate
+	weight := min(float64(frameTime)/float64(latencySmoothing), 1)
+
+	estimator.renderTime += time.Duration(float64(renderTime-estimator.renderTime) * weight)
+	estimator.frameTime += time.Duration(float64(frameTime-estimator.frameTime) * weight)
+}
+
+// Gets the estimated interval to predict the orientation ahead by. Scanout takes a whole frame, so we aim for the middle of it.
+func (estimator *LatencyEstimator) Estimate() time.Duration {
+	return min(estimator.renderTime+estimator.frameTime/2, maxPredictionInterval)
+}
@@ -0,0 +1,189 @@
+package headtracking
+
+import (
+	"math"
+
+	arcommons "git.lunr.sh/UnrealXR/unrealxr/ardriver/commons"
+)
+
+// Vector in renderer coordinates: X is right, Y is up, and -Z is forward.
+type Vector3 struct {
+	X float32
+	Y float32
+	Z float32
+}
+
+// Rotation in renderer coordinates, as a unit quaternion.
+type Quaternion struct {
+	X float32
+	Y float32
+	Z float32
+	W float32
+}
+
+var (
+	IdentityQuaternion = Quaternion{X: 0, Y: 0, Z: 0, W: 1}
+
+	Forward = Vector3{X: 0, Y: 0, Z: -1}
+	Up      = Vector3{X: 0, Y: 1, Z: 0}
+)
+
+// Converts an orientation from a driver (NED: X forward, Y right, Z down) to renderer coordinates.
+func FromDriverQuaternion(quaternion arcommons.Quaternion) Quaternion {
+	// The axes are swapped around by a proper rotation, so the vector part of the quaternion maps just like a vector does
+	return Quaternion{
+		X: quaternion.Y,
+		Y: -quaternion.Z,
+		Z: -quaternion.X,
+		W: quaternion.W,
+	}.Normalize()
+}
+
+// Creates a rotation of angle radians around a unit axis.
+func QuaternionFromAxisAngle(axis Vector3, angle float32) Quaternion {
+	sin, cos := math.Sincos(float64(angle) / 2)
+
+	return Quaternion{
+		X: axis.X * float32(sin),
+		Y: axis.Y * float32(sin),
+		Z: axis.Z * float32(sin),
+		W: float32(cos),
+	}
+}
+
+func (quaternion Quaternion) Length() float32 {
+	return float32(math.Sqrt(float64(quaternion.X*quaternion.X + quaternion.Y*quaternion.Y + quaternion.Z*quaternion.Z + quaternion.W*quaternion.W)))
+}
+
+// Scales the quaternion to unit length. Returns the identity for a zero quaternion.
+func (quaternion Quaternion) Normalize() Quaternion {
+	length := quaternion.Length()
+
+	if length == 0 {
+		return IdentityQuaternion
+	}
+
+	return Quaternion{
+		X: quaternion.X / length,
+		Y: quaternion.Y / length,
+		Z: quaternion.Z / length,
+		W: quaternion.W / length,
+	}
+}
+
+// Gets the inverse rotation of a unit quaternion.
+func (quaternion Quaternion) Conjugate() Quaternion {
+	return Quaternion{
+		X: -quaternion.X,
+		Y: -quaternion.Y,
+		Z: -quaternion.Z,
+		W: quaternion.W,
+	}
+}
+
+// Combines two rotations. The result applies other first, then quaternion.
+func (quaternion Quaternion) Multiply(other Quaternion) Quaternion {
+	return Quaternion{
+		X: quaternion.W*other.X + quaternion.X*other.W + quaternion.Y*other.Z - quaternion.Z*other.Y,
+		Y: quaternion.W*other.Y - quaternion.X*other.Z + quaternion.Y*other.W + quaternion.Z*other.X,
+		Z: quaternion.W*other.Z + quaternion.X*other.Y - quaternion.Y*other.X + quaternion.Z*other.W,
+		W: quaternion.W*other.W - quaternion.X*other.X - quaternion.Y*other.Y - quaternion.Z*other.Z,
+	}
+}
+
+// Rotates a vector by the quaternion.
+func (quaternion Quaternion) Rotate(vector Vector3) Vector3 {
+	// v' = v + 2w(q x v) + 2q x (q x v)
+	crossX := quaternion.Y*vector.Z - quaternion.Z*vector.Y
+	crossY := quaternion.Z*vector.X - quaternion.X*vector.Z
+	crossZ := quaternion.X*vector.Y - quaternion.Y*vector.X
+
+	return Vector3{
+		X: vector.X + 2*(quaternion.W*crossX+quaternion.Y*crossZ-quaternion.Z*crossY),
+		Y: vector.Y + 2*(quaternion.W*crossY+quaternion.Z*crossX-quaternion.X*crossZ),
+		Z: vector.Z + 2*(quaternion.W*crossZ+quaternion.X*crossY-quaternion.Y*crossX),
+	}
+}
+
+func (vector Vector3) Length() float32 {
+	return float32(math.Sqrt(float64(vector.X*vector.X + vector.Y*vector.Y + vector.Z*vector.Z)))
+}
+
+func (vector Vector3) Add(other Vector3) Vector3 {
+	return Vector3{X: vector.X + other.X, Y: vector.Y + other.Y, Z: vector.Z + other.Z}
+}
+
+func (vector Vector3) Scale(factor float32) Vector3 {
+	return Vector3{X: vector.X * factor, Y: vector.Y * factor, Z: vector.Z * factor}
+}
+
+// Interpolates between two rotations along the shortest path. t is between 0 (from) and 1 (to).
+func Slerp(from, to Quaternion, t float32) Quaternion {
+	dot := from.X*to.X + from.Y*to.Y + from.Z*to.Z + from.W*to.W
+
+	// q and -q are the same rotation, so take the shorter way around
+	if dot < 0 {
+		to = Quaternion{X: -to.X, Y: -to.Y, Z: -to.Z, W: -to.W}
+		dot = -dot
+	}
+
+	// Nearly identical rotations would divide by almost zero, and interpolating linearly is just as good there
+	if dot > 0.9995 {
+		return Quaternion{
+			X: from.X + (to.X-from.X)*t,
+			Y: from.Y + (to.Y-from.Y)*t,
+			Z: from.Z + (to.Z-from.Z)*t,
+			W: from.W + (to.W-from.W)*t,
+		}.Normalize()
+	}
+
+	theta := math.Acos(float64(dot))
+	sinTheta := math.Sin(theta)
+
+	fromFactor := float32(math.Sin((1-float64(t))*theta) / sinTheta)
+	toFactor := float32(math.Sin(float64(t)*theta) / sinTheta)
+
+	return Quaternion{
+		X: from.X*fromFactor + to.X*toFactor,
+		Y: from.Y*fromFactor + to.Y*toFactor,
+		Z: from.Z*fromFactor + to.Z*toFactor,
+		W: from.W*fromFactor + to.W*toFactor,
+	}
+}
+
+// Gets the yaw (heading) of a rotation, in radians. Positive values turn left, following the right hand rule around Up.
+func (quaternion Quaternion) Yaw() float32 {
+	forward := quaternion.Rotate(Forward)
+	return float32(math.Atan2(float64(-forward.X), float64(-forward.Z)))
+}
+
+// Gets the pitch of a rotation, in radians. Positive values look up.
+func (quaternion Quaternion) Pitch() float32 {
+	forward := quaternion.Rotate(Forward)
+	return float32(math.Asin(math.Max(-1, math.Min(1, float64(forward.Y)))))
+}
+
+// Creates a rotation from yaw (around Up, positive turns left), then pitch (around X, positive tilts up), then roll (around Z, positive rolls counterclockwise), all in radians.
+func QuaternionFromAngles(yaw, pitch, roll float32) Quaternion {
+	return QuaternionFromAxisAngle(Up, yaw).
+		Multiply(QuaternionFromAxisAngle(Vector3{X: 1, Y: 0, Z: 0}, pitch)).
+		Multiply(QuaternionFromAxisAngle(Vector3{X: 0, Y: 0, Z: 1}, roll))
+}
+
+// Gets the roll of a rotation, in radians, as in QuaternionFromAngles.
+func (quaternion Quaternion) Roll() float32 {
+	// Whatever is left after taking out yaw and pitch is a rotation around the forward axis
+	rollRotation := quaternion.WithoutRoll().Conjugate().Multiply(quaternion)
+	return float32(math.Remainder(2*math.Atan2(float64(rollRotation.Z), float64(rollRotation.W)), 2*math.Pi))
+}
+
+// Gets the rotation with the same yaw and pitch, but without any roll, so the horizon is level.
+func (quaternion Quaternion) WithoutRoll() Quaternion {
+	return QuaternionFromAngles(quaternion.Yaw(), quaternion.Pitch(), 0)
+}
+
+// Gets the angle between two rotations, in radians.
+func AngleBetween(from, to Quaternion) float32 {
+	dot := math.Abs(float64(from.X*to.X + from.Y*to.Y + from.Z*to.Z + from.W*to.W))
+	return float32(2 * math.Acos(math.Min(1, dot)))
+}
@@ -0,0 +1,207 @@
+package headtracking
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	arcommons "git.lunr.sh/UnrealXR/unrealxr/ardriver/commons"
+)
+
+// Furthest ahead an orientation is ever predicted. Predicting further mostly amplifies noise
+const maxPredictionInterval = 50 * time.Millisecond
+
+// Time constant of the smoothing applied to the angular velocity, which would be noisy otherwise
+const angularVelocitySmoothing = 8 * time.Millisecond
+
+// Readings further apart than this don't say anything about the current angular velocity
+const maxReadingGap = 100 * time.Millisecond
+
+// Keeps track of the headset's orientation. Updated from the driver and read by the renderer, which can be on different goroutines.
+type Tracker struct {
+	lock sync.Mutex
+
+	hasOrientation bool
+	orientation    Quaternion
+	// Sensor timestamp of the orientation, in nanoseconds
+	timestamp uint64
+	// When the orientation was received
+	receivedAt time.Time
+	// Angular velocity in renderer coordinates, as an axis scaled by the speed in radians per second
+	angularVelocity Vector3
+	// Orientation in which the user looks straight at the displays
+	reference Quaternion
+	// Reference we're animating away from while recentering
+	previousReference Quaternion
+	recenterStart     time.Time
+
+	// If true, recentering also levels the pitch
+	recenterPitch    bool
+	recenterDuration time.Duration
+	// Applied to every orientation from the driver. Can be nil
+	filter *OrientationFilter
+}
+
+func NewTracker(recenterPitch bool, recenterDuration time.Duration, filter *OrientationFilter) *Tracker {
+	return &Tracker{
+		filter:            filter,
+		orientation:       IdentityQuaternion,
+		reference:         IdentityQuaternion,
+		previousReference: IdentityQuaternion,
+		recenterPitch:     recenterPitch,
+		recenterDuration:  recenterDuration,
+	}
+}
+
+// Gets the reference for facing the given orientation. Roll is never part of the reference, so the horizon stays level.
+func referenceFor(orientation Quaternion, includePitch bool) Quaternion {
+	if includePitch {
+		return orientation.WithoutRoll()
+	}
+
+	return QuaternionFromAxisAngle(Up, orientation.Yaw())
+}
+
+// Records a new absolute orientation from the driver, along with its sensor timestamp in nanoseconds. The first orientation becomes the reference.
+func (tracker *Tracker) Update(orientation arcommons.Quaternion, timestamp uint64) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	newOrientation := FromDriverQuaternion(orientation)
+
+	if tracker.filter != nil {
+		newOrientation = tracker.filter.Filter(newOrientation, timestamp)
+	}
+
+	if tracker.hasOrientation {
+		tracker.updateAngularVelocity(newOrientation, timestamp)
+	}
+
+	tracker.orientation = newOrientation
+	tracker.timestamp = timestamp
+	tracker.receivedAt = time.Now()
+
+	if !tracker.hasOrientation {
+		tracker.hasOrientation = true
+		tracker.reference = referenceFor(tracker.orientation, tracker.recenterPitch)
+		tracker.previousReference = tracker.reference
+	}
+}
+
+// Estimates the angular velocity from the rotation between the last and a new orientation. The lock must be held
+func (tracker *Tracker) updateAngularVelocity(newOrientation Quaternion, timestamp uint64) {
+	if timestamp <= tracker.timestamp {
+		return
+	}
+
+	elapsed := time.Duration(timestamp - tracker.timestamp)
+
+	if elapsed > maxReadingGap {
+		tracker.angularVelocity = Vector3{}
+		return
+	}
+
+	// Rotation from the last orientation to the new one, in world coordinates
+	delta := newOrientation.Multiply(tracker.orientation.Conjugate())
+
+	// q and -q are the same rotation, so take the shorter way around
+	if delta.W < 0 {
+		delta = Quaternion{X: -delta.X, Y: -delta.Y, Z: -delta.Z, W: -delta.W}
+	}
+
+	angle := 2 * math.Acos(math.Min(1, float64(delta.W)))
+	axis := Vector3{X: delta.X, Y: delta.Y, Z: delta.Z}
+	axisLength := axis.Length()
+
+	velocity := Vector3{}
+
+	if axisLength > 0 {
+		velocity = axis.Scale(float32(angle/elapsed.Seconds()) / axisLength)
+	}
+
+	smoothing := 1 - float32(math.Exp(-float64(elapsed)/float64(angularVelocitySmoothing)))
+	tracker.angularVelocity = tracker.angularVelocity.Add(velocity.Add(tracker.angularVelocity.Scale(-1)).Scale(smoothing))
+}
+
+// Gets the reference, taking a running recenter animation into account. The lock must be held
+func (tracker *Tracker) currentReference(now time.Time) Quaternion {
+	elapsed := now.Sub(tracker.recenterStart)
+
+	if tracker.recenterDuration <= 0 || elapsed >= tracker.recenterDuration {
+		return tracker.reference
+	}
+
+	// Smoothstep, so the animation eases in and out
+	t := float32(elapsed) / float32(tracker.recenterDuration)
+	t = t * t * (3 - 2*t)
+
+	return Slerp(tracker.previousReference, tracker.reference, t)
+}
+
+// Gets the orientation relative to the reference. Returns false if the driver hasn't reported an orientation yet.
+func (tracker *Tracker) Orientation() (Quaternion, bool) {
+	return tracker.PredictedOrientation(0)
+}
+
+// Gets the orientation relative to the reference that the headset will likely have after the given interval, extrapolated from the angular velocity.
+// The time since the last reading is predicted as well. Returns false if the driver hasn't reported an orientation yet.
+func (tracker *Tracker) PredictedOrientation(interval time.Duration) (Quaternion, bool) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	if !tracker.hasOrientation {
+		return IdentityQuaternion, false
+	}
+
+	now := time.Now()
+	orientation := tracker.orientation
+
+	if interval > 0 {
+		ahead := min(now.Sub(tracker.receivedAt)+interval, maxPredictionInterval)
+		speed := tracker.angularVelocity.Length()
+
+		if speed > 0 {
+			rotation := QuaternionFromAxisAngle(tracker.angularVelocity.Scale(1/speed), speed*float32(ahead.Seconds()))
+			orientation = rotation.Multiply(orientation).Normalize()
+		}
+	}
+
+	return tracker.currentReference(now).Conjugate().Multiply(orientation), true
+}
+
+// Makes the direction the user is currently facing the new forward, so they look straight at the displays again. The displays move there smoothly.
+func (tracker *Tracker) Recenter() {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	now := time.Now()
+
+	tracker.previousReference = tracker.currentReference(now)
+	tracker.reference = referenceFor(tracker.orientation, tracker.recenterPitch)
+	tracker.recenterStart = now
+}
+
+// Moves the reference along with the head, so the current orientation becomes the given one. Lets the view stay where it was while the head was used for something else.
+// Only yaw and pitch are carried over, so the horizon stays level.
+func (tracker *Tracker) Rebase(orientation Quaternion) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	reference := tracker.currentReference(time.Now())
+	current := reference.Conjugate().Multiply(tracker.orientation)
+
+	// Rotation that the head made from the given orientation to the current one
+	shift := current.WithoutRoll().Multiply(orientation.WithoutRoll().Conjugate())
+
+	tracker.reference = reference.Multiply(shift).Normalize()
+	tracker.previousReference = tracker.reference
+	tracker.recenterStart = time.Time{}
+}
+
+// Gets when the driver last reported an orientation. Returns false if it hasn't reported one yet.
+func (tracker *Tracker) LastReading() (time.Time, bool) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+
+	return tracker.receivedAt, tracker.hasOrientation
+}
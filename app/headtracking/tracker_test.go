@@ -0,0 +1,166 @@
+package headtracking
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	arcommons "git.lunr.sh/UnrealXR/unrealxr/ardriver/commons"
+)
+
+// Inverse of FromDriverQuaternion
+func toDriverQuaternion(quaternion Quaternion) arcommons.Quaternion {
+	return arcommons.Quaternion{
+		X: -quaternion.Z,
+		Y: quaternion.X,
+		Z: -quaternion.Y,
+		W: quaternion.W,
+	}
+}
+
+func degrees(angle float32) float32 {
+	return angle * math.Pi / 180
+}
+
+func isClose(a, b, tolerance float32) bool {
+	return math.Abs(float64(a-b)) <= float64(tolerance)
+}
+
+func TestFromDriverQuaternion(t *testing.T) {
+	tests := []struct {
+		name string
+		// Rotation in the driver's NED coordinates
+		axis  [3]float32
+		angle float32
+		// Expected angles in renderer coordinates
+		yaw, pitch, roll float32
+	}{
+		{"identity", [3]float32{0, 0, 1}, 0, 0, 0, 0},
+		// Positive yaw around down turns right, which is negative in renderer coordinates
+		{"turn right", [3]float32{0, 0, 1}, degrees(30), degrees(-30), 0, 0},
+		// Positive pitch around right lifts the nose
+		{"look up", [3]float32{0, 1, 0}, degrees(20), 0, degrees(20), 0},
+		// Positive roll around forward lowers the right side, which is clockwise as seen by the user
+		{"roll right", [3]float32{1, 0, 0}, degrees(15), 0, 0, degrees(-15)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sin, cos := math.Sincos(float64(test.angle) / 2)
+
+			orientation := FromDriverQuaternion(arcommons.Quaternion{
+				X: test.axis[0] * float32(sin),
+				Y: test.axis[1] * float32(sin),
+				Z: test.axis[2] * float32(sin),
+				W: float32(cos),
+			})
+
+			if !isClose(orientation.Yaw(), test.yaw, 1e-5) || !isClose(orientation.Pitch(), test.pitch, 1e-5) || !isClose(orientation.Roll(), test.roll, 1e-5) {
+				t.Errorf("got yaw %g, pitch %g, roll %g, expected %g, %g, %g", orientation.Yaw(), orientation.Pitch(), orientation.Roll(), test.yaw, test.pitch, test.roll)
+			}
+		})
+	}
+}
+
+func TestAnglesRoundTrip(t *testing.T) {
+	tests := []struct {
+		yaw, pitch, roll float32
+	}{
+		{0, 0, 0},
+		{degrees(45), 0, 0},
+		{degrees(-170), degrees(10), degrees(-5)},
+		{degrees(90), degrees(-60), degrees(30)},
+		{degrees(179), degrees(80), degrees(-120)},
+		{degrees(-30), degrees(-85), degrees(170)},
+	}
+
+	for _, test := range tests {
+		orientation := QuaternionFromAngles(test.yaw, test.pitch, test.roll)
+
+		if !isClose(orientation.Yaw(), test.yaw, 1e-4) || !isClose(orientation.Pitch(), test.pitch, 1e-4) || !isClose(orientation.Roll(), test.roll, 1e-4) {
+			t.Errorf("%g, %g, %g came back as %g, %g, %g", test.yaw, test.pitch, test.roll, orientation.Yaw(), orientation.Pitch(), orientation.Roll())
+		}
+
+		if angle := AngleBetween(orientation, FromDriverQuaternion(toDriverQuaternion(orientation))); angle > 1e-3 {
+			t.Errorf("%g, %g, %g is %g radians off after converting to driver coordinates and back", test.yaw, test.pitch, test.roll, angle)
+		}
+
+		if angle := AngleBetween(orientation.WithoutRoll(), QuaternionFromAngles(test.yaw, test.pitch, 0)); angle > 1e-3 {
+			t.Errorf("%g, %g, %g is %g radians off after removing the roll", test.yaw, test.pitch, test.roll, angle)
+		}
+	}
+}
+
+func TestPredictedOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		// Turning rate in radians per second, around Up
+		rate     float64
+		interval time.Duration
+		filter   func() *OrientationFilter
+		// Time between the last two readings
+		lastGap time.Duration
+		// Expected yaw relative to the first reading, as time turned at rate
+		expectedAhead time.Duration
+		tolerance     float32
+	}{
+		{"still", 0, 20 * time.Millisecond, nil, time.Millisecond, 0, 0.001},
+		{"no interval", 2, 0, nil, time.Millisecond, 0, 0.002},
+		{"turning left", 2, 20 * time.Millisecond, nil, time.Millisecond, 20 * time.Millisecond, 0.005},
+		{"turning right", -3, 20 * time.Millisecond, nil, time.Millisecond, 20 * time.Millisecond, 0.005},
+		{"interval is capped", 2, time.Second, nil, time.Millisecond, maxPredictionInterval, 0.005},
+		// Readings that far apart don't tell how fast the head is turning now
+		{"after a gap", 2, 20 * time.Millisecond, nil, 2 * maxReadingGap, 0, 0.002},
+		// The exponential filter lags by its time constant, about 16 ms, which predicting as far ahead makes up for
+		{
+			"filtered",
+			2,
+			16 * time.Millisecond,
+			func() *OrientationFilter {
+				return NewOrientationFilter(NewExponentialFilter(10), &PassthroughFilter{}, &PassthroughFilter{})
+			},
+			time.Millisecond,
+			0,
+			0.01,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var filter *OrientationFilter
+
+			if test.filter != nil {
+				filter = test.filter()
+			}
+
+			tracker := NewTracker(false, 0, filter)
+			elapsed := time.Duration(0)
+
+			for sample := range 500 {
+				if sample == 499 {
+					elapsed += test.lastGap
+				} else if sample > 0 {
+					elapsed += testSampleInterval
+				}
+
+				orientation := QuaternionFromAngles(float32(test.rate*elapsed.Seconds()), 0, 0)
+				tracker.Update(toDriverQuaternion(orientation), uint64(elapsed))
+			}
+
+			predicted, ok := tracker.PredictedOrientation(test.interval)
+
+			if !ok {
+				t.Fatal("tracker has no orientation")
+			}
+
+			expected := float32(test.rate * (elapsed + test.expectedAhead).Seconds())
+
+			// Allow for the real time that passed since the last reading, which is predicted as well
+			tolerance := test.tolerance + float32(math.Abs(test.rate)*0.002)
+
+			if !isClose(predicted.Yaw(), expected, tolerance) {
+				t.Errorf("predicted yaw is %g, expected %g", predicted.Yaw(), expected)
+			}
+		})
+	}
+}
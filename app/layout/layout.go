@@ -0,0 +1,203 @@
+package layout
+
+import (
+	"fmt"
+	"math"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/headtracking"
+)
+
+// Generators that arrange displays
+const (
+	KindArc     = "arc"
+	KindRow     = "row"
+	KindGrid    = "grid"
+	KindStack   = "stack"
+	KindCockpit = "cockpit"
+)
+
+// Pose of a display relative to the viewer, who is at the origin looking towards -Z with +Y up.
+type Pose struct {
+	// Center of the display, in world units
+	Position headtracking.Vector3
+	// Rotation of the display. Without rotation, the display faces the viewer from straight ahead
+	Rotation headtracking.Quaternion
+	// Size of the display, relative to its default size
+	Scale float32
+}
+
+// What the generators base a layout on.
+type Parameters struct {
+	Count int
+	// Distance from the viewer to the center display, in world units
+	Distance float32
+	// Size of a display at scale 1, in world units
+	Width  float32
+	Height float32
+	// Gap between neighbouring displays, in world units
+	Spacing float32
+	// Angle between neighbouring displays in curved layouts, in degrees
+	Angle float32
+	// Radius of the sphere that curved layouts place displays on. The sphere always touches the center display
+	Radius float32
+	// Number of columns in grid layouts
+	Columns int
+}
+
+// Gets the smallest radius at which a display fits into the FOV (in degrees) as a whole.
+func FittingRadius(width, height, fovX, fovY float32) float32 {
+	radiusX := (width / 2) / float32(math.Tan(float64(fovX)*math.Pi/180/2))
+	radiusY := (height / 2) / float32(math.Tan(float64(fovY)*math.Pi/180/2))
+
+	return max(radiusX, radiusY)
+}
+
+// Arranges displays with the given generator.
+func Generate(kind string, parameters Parameters) ([]Pose, error) {
+	switch kind {
+	case KindArc:
+		return Arc(parameters), nil
+	case KindRow:
+		return Row(parameters), nil
+	case KindGrid:
+		return Grid(parameters), nil
+	case KindStack:
+		return Stack(parameters), nil
+	case KindCockpit:
+		return Cockpit(parameters), nil
+	default:
+		return nil, fmt.Errorf("unknown layout '%s'", kind)
+	}
+}
+
+// Gets the offset of an item from the middle of a centered line of items, in steps
+func centeredOffset(index, count int) float32 {
+	return float32(index) - float32(count-1)/2
+}
+
+// Places a display on the sphere of curved layouts, facing the sphere's center. Positive yaw is to the right, positive pitch is up (both in radians)
+func onSphere(parameters Parameters, yaw, pitch float32) Pose {
+	// The sphere touches the center display, so it's centered behind the viewer unless the radius matches the distance
+	center := headtracking.Vector3{X: 0, Y: 0, Z: parameters.Radius - parameters.Distance}
+
+	// Facing the center means turning the other way than the display is placed
+	rotation := headtracking.QuaternionFromAngles(-yaw, pitch, 0)
+
+	return Pose{
+		Position: center.Add(positionOf(yaw, pitch, parameters.Radius)),
+		Rotation: rotation,
+		Scale:    1,
+	}
+}
+
+// Gets the pitch between rows on the sphere, so rows are spaced like columns in a row layout
+func rowPitch(parameters Parameters) float32 {
+	halfStep := (parameters.Height + parameters.Spacing) / 2
+	return 2 * float32(math.Asin(math.Min(1, float64(halfStep/parameters.Radius))))
+}
+
+// Places displays next to each other on a horizontal arc, centered in front of the viewer.
+func Arc(parameters Parameters) []Pose {
+	poses := make([]Pose, parameters.Count)
+	angle := parameters.Angle * math.Pi / 180
+
+	for i := range poses {
+		poses[i] = onSphere(parameters, centeredOffset(i, parameters.Count)*angle, 0)
+	}
+
+	return poses
+}
+
+// Places displays next to each other in a flat row, centered in front of the viewer.
+func Row(parameters Parameters) []Pose {
+	poses := make([]Pose, parameters.Count)
+
+	for i := range poses {
+		poses[i] = Pose{
+			Position: headtracking.Vector3{
+				X: centeredOffset(i, parameters.Count) * (parameters.Width + parameters.Spacing),
+				Y: 0,
+				Z: -parameters.Distance,
+			},
+			Rotation: headtracking.IdentityQuaternion,
+			Scale:    1,
+		}
+	}
+
+	return poses
+}
+
+// Places displays in rows and columns on a section of the sphere, filling rows from the top left.
+func Grid(parameters Parameters) []Pose {
+	columns := max(1, min(parameters.Columns, parameters.Count))
+	rows := (parameters.Count + columns - 1) / columns
+
+	poses := make([]Pose, parameters.Count)
+	yawStep := parameters.Angle * math.Pi / 180
+	pitchStep := rowPitch(parameters)
+
+	for i := range poses {
+		row, column := i/columns, i%columns
+		columnsInRow := min(columns, parameters.Count-row*columns)
+
+		// Rows are numbered from the top, and a last row that isn't full is centered
+		poses[i] = onSphere(parameters, centeredOffset(column, columnsInRow)*yawStep, -centeredOffset(row, rows)*pitchStep)
+	}
+
+	return poses
+}
+
+// Places displays above each other, centered in front of the viewer.
+func Stack(parameters Parameters) []Pose {
+	parameters.Columns = 1
+	return Grid(parameters)
+}
+
+// Places the first display in front of the viewer, and the others in an arc below it, tilted up towards the viewer like the instruments of a cockpit.
+func Cockpit(parameters Parameters) []Pose {
+	poses := make([]Pose, parameters.Count)
+
+	if parameters.Count == 0 {
+		return poses
+	}
+
+	poses[0] = onSphere(parameters, 0, 0)
+
+	yawStep := parameters.Angle * math.Pi / 180
+	pitch := -rowPitch(parameters)
+
+	for i := 1; i < parameters.Count; i++ {
+		poses[i] = onSphere(parameters, centeredOffset(i-1, parameters.Count-1)*yawStep, pitch)
+	}
+
+	return poses
+}
+
+// Replaces parts of a pose. Nil fields keep what the generator came up with.
+type PoseOverride struct {
+	Position *headtracking.Vector3
+	// Yaw, pitch and roll in degrees, as in headtracking.QuaternionFromAngles
+	Rotation *[3]float32
+	Scale    *float32
+}
+
+// Gets the pose with the override applied.
+func (pose Pose) WithOverride(override PoseOverride) Pose {
+	if override.Position != nil {
+		pose.Position = *override.Position
+	}
+
+	if override.Rotation != nil {
+		pose.Rotation = headtracking.QuaternionFromAngles(
+			override.Rotation[0]*math.Pi/180,
+			override.Rotation[1]*math.Pi/180,
+			override.Rotation[2]*math.Pi/180,
+		)
+	}
+
+	if override.Scale != nil {
+		pose.Scale = *override.Scale
+	}
+
+	return pose
+}
@@ -0,0 +1,233 @@
+package layout
+
+import (
+	"math"
+	"testing"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/headtracking"
+)
+
+var testParameters = Parameters{
+	Distance: 2,
+	Width:    1.6,
+	Height:   0.9,
+	Spacing:  0.1,
+	Angle:    30,
+	Radius:   2,
+	Columns:  2,
+}
+
+func withCount(parameters Parameters, count int) Parameters {
+	parameters.Count = count
+	return parameters
+}
+
+func isClose(a, b float32) bool {
+	return math.Abs(float64(a-b)) <= 1e-4
+}
+
+func degrees(angle float32) float32 {
+	return angle * math.Pi / 180
+}
+
+// Checks the direction of every pose as seen from the viewer, in degrees
+func expectDirections(t *testing.T, poses []Pose, yaws, pitches []float32) {
+	t.Helper()
+
+	if len(poses) != len(yaws) {
+		t.Fatalf("got %d poses, expected %d", len(poses), len(yaws))
+	}
+
+	for i, pose := range poses {
+		yaw, pitch, _ := directionOf(pose.Position)
+
+		if !isClose(yaw, degrees(yaws[i])) || !isClose(pitch, degrees(pitches[i])) {
+			t.Errorf("display %d is at yaw %g, pitch %g, expected %g, %g", i, yaw*180/math.Pi, pitch*180/math.Pi, yaws[i], pitches[i])
+		}
+	}
+}
+
+// Checks that every pose has scale 1 and faces the viewer
+func expectFacingViewer(t *testing.T, poses []Pose) {
+	t.Helper()
+
+	for i, pose := range poses {
+		if angle := angleFromFacing(pose); angle > 1e-3 {
+			t.Errorf("display %d is turned %g radians away from the viewer", i, angle)
+		}
+
+		if pose.Scale != 1 {
+			t.Errorf("display %d has scale %g", i, pose.Scale)
+		}
+	}
+}
+
+func TestArcCentering(t *testing.T) {
+	tests := []struct {
+		count int
+		yaws  []float32
+	}{
+		{1, []float32{0}},
+		{2, []float32{-15, 15}},
+		{3, []float32{-30, 0, 30}},
+		{4, []float32{-45, -15, 15, 45}},
+	}
+
+	for _, test := range tests {
+		poses := Arc(withCount(testParameters, test.count))
+
+		expectDirections(t, poses, test.yaws, make([]float32, test.count))
+		expectFacingViewer(t, poses)
+
+		for i, pose := range poses {
+			if distance := pose.Position.Length(); !isClose(distance, testParameters.Distance) {
+				t.Errorf("%d displays: display %d is %g away, expected %g", test.count, i, distance, testParameters.Distance)
+			}
+		}
+	}
+}
+
+func TestArcTouchesCenterDisplay(t *testing.T) {
+	parameters := withCount(testParameters, 3)
+	parameters.Radius = 4
+
+	poses := Arc(parameters)
+
+	// The sphere is centered behind the viewer, so the middle display stays at the distance while the others curve in less
+	if !isClose(poses[1].Position.Z, -parameters.Distance) || !isClose(poses[1].Position.X, 0) {
+		t.Errorf("middle display is at %v, expected straight ahead at %g", poses[1].Position, parameters.Distance)
+	}
+
+	if !isClose(poses[0].Position.X, -poses[2].Position.X) || !isClose(poses[0].Position.Z, poses[2].Position.Z) {
+		t.Errorf("outer displays at %v and %v aren't mirrored", poses[0].Position, poses[2].Position)
+	}
+}
+
+func TestRowCentering(t *testing.T) {
+	step := testParameters.Width + testParameters.Spacing
+
+	tests := []struct {
+		count int
+		// X of every display, in steps
+		offsets []float32
+	}{
+		{1, []float32{0}},
+		{2, []float32{-0.5, 0.5}},
+		{3, []float32{-1, 0, 1}},
+		{4, []float32{-1.5, -0.5, 0.5, 1.5}},
+	}
+
+	for _, test := range tests {
+		poses := Row(withCount(testParameters, test.count))
+
+		if len(poses) != test.count {
+			t.Fatalf("got %d poses, expected %d", len(poses), test.count)
+		}
+
+		for i, pose := range poses {
+			expected := headtracking.Vector3{X: test.offsets[i] * step, Y: 0, Z: -testParameters.Distance}
+
+			if !isClose(pose.Position.X, expected.X) || !isClose(pose.Position.Y, expected.Y) || !isClose(pose.Position.Z, expected.Z) {
+				t.Errorf("%d displays: display %d is at %v, expected %v", test.count, i, pose.Position, expected)
+			}
+
+			if pose.Rotation != headtracking.IdentityQuaternion {
+				t.Errorf("%d displays: display %d is rotated", test.count, i)
+			}
+		}
+	}
+}
+
+func TestGridRowPitch(t *testing.T) {
+	poses := Grid(withCount(testParameters, 4))
+
+	// Vertically neighbouring displays are as far apart as horizontally neighbouring ones in a row
+	for _, pair := range [][2]int{{0, 2}, {1, 3}} {
+		gap := poses[pair[0]].Position.Add(poses[pair[1]].Position.Scale(-1)).Length()
+
+		if !isClose(gap, testParameters.Height+testParameters.Spacing) {
+			t.Errorf("displays %d and %d are %g apart, expected %g", pair[0], pair[1], gap, testParameters.Height+testParameters.Spacing)
+		}
+	}
+}
+
+func TestGridColumnWrap(t *testing.T) {
+	pitch := rowPitch(testParameters) * 180 / math.Pi
+
+	tests := []struct {
+		name    string
+		count   int
+		columns int
+		yaws    []float32
+		pitches []float32
+	}{
+		{"full rows", 4, 2, []float32{-15, 15, -15, 15}, []float32{pitch / 2, pitch / 2, -pitch / 2, -pitch / 2}},
+		// A last row that isn't full is centered
+		{"partial last row", 5, 2, []float32{-15, 15, -15, 15, 0}, []float32{pitch, pitch, 0, 0, -pitch}},
+		{"odd columns", 5, 3, []float32{-30, 0, 30, -15, 15}, []float32{pitch / 2, pitch / 2, pitch / 2, -pitch / 2, -pitch / 2}},
+		{"more columns than displays", 3, 5, []float32{-30, 0, 30}, []float32{0, 0, 0}},
+		{"no columns", 2, 0, []float32{0, 0}, []float32{pitch / 2, -pitch / 2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parameters := withCount(testParameters, test.count)
+			parameters.Columns = test.columns
+
+			poses := Grid(parameters)
+
+			expectDirections(t, poses, test.yaws, test.pitches)
+			expectFacingViewer(t, poses)
+		})
+	}
+}
+
+func TestStack(t *testing.T) {
+	pitch := rowPitch(testParameters) * 180 / math.Pi
+	poses := Stack(withCount(testParameters, 3))
+
+	// Columns are ignored
+	expectDirections(t, poses, []float32{0, 0, 0}, []float32{pitch, 0, -pitch})
+	expectFacingViewer(t, poses)
+}
+
+func TestCockpit(t *testing.T) {
+	pitch := rowPitch(testParameters) * 180 / math.Pi
+
+	tests := []struct {
+		count   int
+		yaws    []float32
+		pitches []float32
+	}{
+		{0, []float32{}, []float32{}},
+		{1, []float32{0}, []float32{0}},
+		{2, []float32{0, 0}, []float32{0, -pitch}},
+		{4, []float32{0, -30, 0, 30}, []float32{0, -pitch, -pitch, -pitch}},
+		{5, []float32{0, -45, -15, 15, 45}, []float32{0, -pitch, -pitch, -pitch, -pitch}},
+	}
+
+	for _, test := range tests {
+		poses := Cockpit(withCount(testParameters, test.count))
+
+		expectDirections(t, poses, test.yaws, test.pitches)
+
+		// The instruments below are tilted up towards the viewer
+		expectFacingViewer(t, poses)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	for _, kind := range []string{KindArc, KindRow, KindGrid, KindStack, KindCockpit} {
+		poses, err := Generate(kind, withCount(testParameters, 3))
+
+		if err != nil {
+			t.Errorf("failed to generate '%s': %s", kind, err)
+		} else if len(poses) != 3 {
+			t.Errorf("'%s' generated %d poses, expected 3", kind, len(poses))
+		}
+	}
+
+	if _, err := Generate("circle", withCount(testParameters, 3)); err == nil {
+		t.Error("unknown layout didn't fail")
+	}
+}
@@ -0,0 +1,136 @@
+package layout
+
+import (
+	"math"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/headtracking"
+)
+
+// Line between two points relative to the viewer, showing what a display was lined up with.
+type Guide struct {
+	From headtracking.Vector3
+	To   headtracking.Vector3
+}
+
+// Gets the direction of a position as seen from the viewer. Positive yaw is to the right, positive pitch is up (both in radians), like in onSphere
+func directionOf(position headtracking.Vector3) (yaw, pitch, distance float32) {
+	distance = position.Length()
+
+	if distance == 0 {
+		return 0, 0, 0
+	}
+
+	yaw = float32(math.Atan2(float64(position.X), float64(-position.Z)))
+	pitch = float32(math.Asin(math.Max(-1, math.Min(1, float64(position.Y/distance)))))
+
+	return yaw, pitch, distance
+}
+
+// Gets the position in a direction from the viewer, as returned by directionOf
+func positionOf(yaw, pitch, distance float32) headtracking.Vector3 {
+	sinYaw, cosYaw := math.Sincos(float64(yaw))
+	sinPitch, cosPitch := math.Sincos(float64(pitch))
+
+	return headtracking.Vector3{
+		X: distance * float32(cosPitch*sinYaw),
+		Y: distance * float32(sinPitch),
+		Z: -distance * float32(cosPitch*cosYaw),
+	}
+}
+
+// Finds the closest of the candidates to a value, if it's at most threshold away
+func closest(value float32, candidates []float32, threshold float32) (int, bool) {
+	best := -1
+
+	for i, candidate := range candidates {
+		difference := float32(math.Abs(float64(value - candidate)))
+
+		if difference <= threshold && (best == -1 || difference < float32(math.Abs(float64(value-candidates[best])))) {
+			best = i
+		}
+	}
+
+	return best, best != -1
+}
+
+// Lines a pose up with the others and with straight ahead, if it's within threshold (in radians) of them.
+// Displays snap to the same direction as others horizontally or vertically, and to facing the viewer or sharing another display's rotation.
+// Returns the snapped pose and guides for everything it was lined up with.
+func Snap(pose Pose, others []Pose, threshold float32) (Pose, []Guide) {
+	if threshold <= 0 {
+		return pose, nil
+	}
+
+	yaw, pitch, distance := directionOf(pose.Position)
+
+	if distance == 0 {
+		return pose, nil
+	}
+
+	// Straight ahead comes first, so it's preferred when it's as close as another display
+	anchors := []headtracking.Vector3{positionOf(0, 0, distance)}
+	yaws := []float32{0}
+	pitches := []float32{0}
+
+	for _, other := range others {
+		otherYaw, otherPitch, otherDistance := directionOf(other.Position)
+
+		if otherDistance == 0 {
+			continue
+		}
+
+		anchors = append(anchors, other.Position)
+		yaws = append(yaws, otherYaw)
+		pitches = append(pitches, otherPitch)
+	}
+
+	yawMatch, snapsYaw := closest(yaw, yaws, threshold)
+	pitchMatch, snapsPitch := closest(pitch, pitches, threshold)
+
+	if snapsYaw {
+		yaw = yaws[yawMatch]
+	}
+
+	if snapsPitch {
+		pitch = pitches[pitchMatch]
+	}
+
+	wasFacingViewer := angleFromFacing(pose) <= threshold
+	pose.Position = positionOf(yaw, pitch, distance)
+
+	guides := []Guide{}
+
+	if snapsYaw {
+		guides = append(guides, Guide{From: anchors[yawMatch], To: pose.Position})
+	}
+
+	if snapsPitch && (!snapsYaw || pitchMatch != yawMatch) {
+		guides = append(guides, Guide{From: anchors[pitchMatch], To: pose.Position})
+	}
+
+	// Facing the viewer wins over sharing a rotation, as that's what the curved layouts do
+	if wasFacingViewer {
+		pose.Rotation = facingViewer(pose.Position)
+		return pose, guides
+	}
+
+	for _, other := range others {
+		if headtracking.AngleBetween(pose.Rotation, other.Rotation) <= threshold {
+			pose.Rotation = other.Rotation
+			break
+		}
+	}
+
+	return pose, guides
+}
+
+// Gets the rotation that makes a display at a position face the viewer
+func facingViewer(position headtracking.Vector3) headtracking.Quaternion {
+	yaw, pitch, _ := directionOf(position)
+	return headtracking.QuaternionFromAngles(-yaw, pitch, 0)
+}
+
+// Gets how far a pose is turned away from facing the viewer, in radians
+func angleFromFacing(pose Pose) float32 {
+	return headtracking.AngleBetween(pose.Rotation, facingViewer(pose.Position))
+}
@@ -0,0 +1,104 @@
+package layout
+
+import (
+	"math"
+	"testing"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/headtracking"
+)
+
+// Gets a pose in a direction from the viewer (in degrees), facing the viewer
+func poseAt(yaw, pitch, distance float32) Pose {
+	position := positionOf(degrees(yaw), degrees(pitch), distance)
+
+	return Pose{
+		Position: position,
+		Rotation: facingViewer(position),
+		Scale:    1,
+	}
+}
+
+func TestSnapPosition(t *testing.T) {
+	tests := []struct {
+		name string
+		pose Pose
+		// Snap angle in degrees
+		threshold float32
+		others    []Pose
+		// Expected direction in degrees
+		yaw, pitch float32
+		guides     int
+	}{
+		{"disabled", poseAt(1, 1, 2), 0, nil, 1, 1, 0},
+		// Lining up with the same anchor both ways only needs one guide
+		{"straight ahead", poseAt(1.5, -1.5, 2), 2, nil, 0, 0, 1},
+		{"just outside", poseAt(2.5, -2.5, 2), 2, nil, 2.5, -2.5, 0},
+		{"only yaw", poseAt(1.5, 10, 2), 2, nil, 0, 10, 1},
+		// Straight ahead wins over a display that is just as close
+		{"tie", poseAt(1, 0, 2), 2, []Pose{poseAt(2, 0, 2)}, 0, 0, 1},
+		{"same display", poseAt(31, 9, 2), 2, []Pose{poseAt(30, 10, 3)}, 30, 10, 1},
+		{"two displays", poseAt(31, 19, 2), 2, []Pose{poseAt(30, 0, 2), poseAt(0, 20, 2)}, 30, 20, 2},
+		{"closest display", poseAt(31.2, 5, 2), 2, []Pose{poseAt(30, 0, 2), poseAt(33, 0, 2)}, 30, 5, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, distance := directionOf(test.pose.Position)
+			snapped, guides := Snap(test.pose, test.others, degrees(test.threshold))
+
+			yaw, pitch, snappedDistance := directionOf(snapped.Position)
+
+			if !isClose(yaw, degrees(test.yaw)) || !isClose(pitch, degrees(test.pitch)) {
+				t.Errorf("snapped to yaw %g, pitch %g, expected %g, %g", yaw*180/math.Pi, pitch*180/math.Pi, test.yaw, test.pitch)
+			}
+
+			if !isClose(snappedDistance, distance) {
+				t.Errorf("distance changed from %g to %g", distance, snappedDistance)
+			}
+
+			if len(guides) != test.guides {
+				t.Errorf("got %d guides, expected %d", len(guides), test.guides)
+			}
+
+			for _, guide := range guides {
+				if guide.To != snapped.Position {
+					t.Errorf("guide ends at %v instead of the snapped display at %v", guide.To, snapped.Position)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapRotation(t *testing.T) {
+	tilted := poseAt(20, 0, 2)
+	tilted.Rotation = headtracking.QuaternionFromAngles(degrees(10), degrees(5), 0)
+
+	nearlyTilted := poseAt(40, 0, 2)
+	nearlyTilted.Rotation = headtracking.QuaternionFromAngles(degrees(11), degrees(5), 0)
+
+	nearlyFacing := poseAt(40, 0, 2)
+	nearlyFacing.Rotation = headtracking.QuaternionFromAngles(degrees(-39), 0, 0)
+
+	tests := []struct {
+		name      string
+		pose      Pose
+		threshold float32
+		others    []Pose
+		expected  headtracking.Quaternion
+	}{
+		{"facing the viewer", nearlyFacing, 2, []Pose{tilted}, facingViewer(nearlyFacing.Position)},
+		{"sharing a rotation", nearlyTilted, 2, []Pose{tilted}, tilted.Rotation},
+		{"too far from both", nearlyTilted, 0.5, []Pose{tilted}, nearlyTilted.Rotation},
+		{"disabled", nearlyFacing, 0, []Pose{tilted}, nearlyFacing.Rotation},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			snapped, _ := Snap(test.pose, test.others, degrees(test.threshold))
+
+			if angle := headtracking.AngleBetween(snapped.Rotation, test.expected); angle > 1e-3 {
+				t.Errorf("rotation is %g radians off", angle)
+			}
+		})
+	}
+}
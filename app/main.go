@@ -3,7 +3,9 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path"
@@ -13,7 +15,9 @@ import (
 	libconfig "git.lunr.sh/UnrealXR/unrealxr/app/config"
 	"git.lunr.sh/UnrealXR/unrealxr/app/edidtools"
 	"git.lunr.sh/UnrealXR/unrealxr/app/platformtools"
+	"git.lunr.sh/UnrealXR/unrealxr/app/privhelper"
 	"git.lunr.sh/UnrealXR/unrealxr/app/renderer"
+	"git.lunr.sh/UnrealXR/unrealxr/app/uinput"
 	"git.lunr.sh/UnrealXR/unrealxr/edidpatcher"
 	"git.lunr.sh/UnrealXR/unrealxr/evdi/libevdi"
 	"github.com/charmbracelet/log"
@@ -25,62 +29,146 @@ import (
 	rl "git.lunr.sh/UnrealXR/raylib-go/raylib"
 )
 
-func mainEntrypoint(context.Context, *cli.Command) error {
-	log.Info("Initializing UnrealXR")
+// Checks if we were started by PrivilegeEscalate, which passes the config to us over stdin
+func isElevatedChild() bool {
+	return os.Geteuid() == 0 && os.Getenv("UXR_CONFIG_ON_STDIN") == "1"
+}
 
-	// Allow for overriding the config directory
-	configDir := os.Getenv("UNREALXR_CONFIG_PATH")
+// Reads config.yml from the config directory. Symlinks are never followed, and as root the default config file is never created
+func readConfigFile(configDir string) ([]byte, error) {
+	configPath := path.Join(configDir, "config.yml")
+	configFile, err := os.OpenFile(configPath, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
 
-	if configDir == "" {
-		configDir = configdir.LocalConfig("unrealxr")
-		err := configdir.MakePath(configDir)
+	if errors.Is(err, os.ErrNotExist) {
+		if os.Geteuid() == 0 {
+			log.Debug("No config file found, using the default config")
+			return libconfig.InitialConfig, nil
+		}
+
+		log.Debug("Creating default config file")
+		configFile, err := os.OpenFile(configPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, 0644)
 
 		if err != nil {
-			return fmt.Errorf("failed to ensure config directory exists: %w", err)
+			return nil, fmt.Errorf("failed to create initial config file: %w", err)
 		}
+
+		defer configFile.Close()
+
+		if _, err := configFile.Write(libconfig.InitialConfig); err != nil {
+			return nil, fmt.Errorf("failed to create initial config file: %w", err)
+		}
+
+		return libconfig.InitialConfig, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
 	}
 
-	_, err := os.Stat(path.Join(configDir, "config.yml"))
+	defer configFile.Close()
+	configBytes, err := io.ReadAll(configFile)
 
 	if err != nil {
-		log.Debug("Creating default config file")
-		err := os.WriteFile(path.Join(configDir, "config.yml"), libconfig.InitialConfig, 0644)
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	return configBytes, nil
+}
+
+// Loads and validates the config, creating the default config file if it doesn't exist yet. Returns the config and the config directory.
+//
+// When we were started by PrivilegeEscalate, the config is read from stdin instead and the config directory is empty.
+func loadConfig() (*libconfig.Config, string, error) {
+	var configBytes []byte
+	var configDir string
+	var err error
+
+	if isElevatedChild() {
+		// Our unprivileged parent already loaded and validated the config, so we don't touch the user's files as root
+		configBytes, err = platformtools.ReadForwardedConfig(os.Stdin)
 
 		if err != nil {
-			return fmt.Errorf("failed to create initial config file: %w", err)
+			return nil, "", fmt.Errorf("failed to read config from parent process: %w", err)
 		}
-	}
+	} else {
+		// Allow for overriding the config directory
+		configDir = os.Getenv("UNREALXR_CONFIG_PATH")
 
-	// Read and parse the config file
-	configBytes, err := os.ReadFile(path.Join(configDir, "config.yml"))
+		if configDir == "" {
+			configDir = configdir.LocalConfig("unrealxr")
 
-	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+			if os.Geteuid() != 0 {
+				if err := configdir.MakePath(configDir); err != nil {
+					return nil, "", fmt.Errorf("failed to ensure config directory exists: %w", err)
+				}
+			}
+		}
+
+		configBytes, err = readConfigFile(configDir)
+
+		if err != nil {
+			return nil, "", err
+		}
 	}
 
 	config := &libconfig.Config{}
 	err = yaml.Unmarshal(configBytes, config)
 
 	if err != nil {
-		return fmt.Errorf("failed to parse config file: %w", err)
+		return nil, "", fmt.Errorf("failed to parse config file: %w", err)
 	}
 
-	// Run privilege escalation if needed
-	if os.Geteuid() != -1 && os.Getenv("UXR_HAS_PRIVESC") != "1" {
-		log.Info("Attempting to escalate privileges and restart process")
+	libconfig.InitializePotentiallyMissingConfigValues(config)
 
-		if os.Getuid() == 0 || os.Geteuid() == 0 {
-			log.Warn("Running directly as root is discouraged and not recommended. This application will automatically escelate to root when needed")
-		} else {
-			err := platformtools.PrivilegeEscalate(configDir)
+	if err := libconfig.Validate(config); err != nil {
+		return nil, "", fmt.Errorf("invalid config: %w", err)
+	}
 
-			if err != nil {
-				return fmt.Errorf("failed to escalate privileges: %w", err)
-			}
+	return config, configDir, nil
+}
+
+func mainEntrypoint(_ context.Context, cmd *cli.Command) error {
+	log.Info("Initializing UnrealXR")
+
+	config, configDir, err := loadConfig()
+
+	if err != nil {
+		return err
+	}
+
+	if profile := cmd.String("profile"); profile != "" {
+		if _, ok := config.Profiles[profile]; !ok {
+			return fmt.Errorf("profile '%s' doesn't exist", profile)
 		}
+
+		config.Profile = &profile
 	}
 
-	libconfig.InitializePotentiallyMissingConfigValues(config)
+	log.Infof("Using profile '%s'", *config.Profile)
+
+	// Run privilege escalation if needed. In helper mode, this happens once we know which device we need access to
+	var privilegedOperations privhelper.Operations
+
+	if os.Getuid() == 0 || os.Geteuid() == 0 {
+		if os.Getenv("UXR_HAS_PRIVESC") != "1" {
+			log.Warn("Running directly as root is discouraged and not recommended. This application will automatically escelate to root when needed")
+		}
+
+		privilegedOperations = privhelper.NewDirectOperations()
+	} else if *config.Privileges.Mode == libconfig.PrivilegeModeLegacy {
+		log.Info("Attempting to escalate privileges and restart process")
+		validatedConfig, err := libconfig.Marshal(config)
+
+		if err != nil {
+			return fmt.Errorf("failed to serialize config: %w", err)
+		}
+
+		err = platformtools.PrivilegeEscalate(escalationOptions(config), validatedConfig)
+
+		if err != nil {
+			return fmt.Errorf("failed to escalate privileges: %w", err)
+		}
+
+		return nil
+	}
 
 	// Allow for clean exits
 	c := make(chan os.Signal, 1)
@@ -101,6 +189,15 @@ func mainEntrypoint(context.Context, *cli.Command) error {
 	}
 
 	log.Debug("Got EDID file and metadata")
+
+	if privilegedOperations == nil {
+		privilegedOperations, err = initializePrivilegedOperations(escalationOptions(config), displayMetadata)
+
+		if err != nil {
+			return fmt.Errorf("failed to set up privileged operations: %w", err)
+		}
+	}
+
 	log.Debug("Patching EDID firmware to be specialized")
 
 	patchedFirmware, err := edidpatcher.PatchEDIDToBeSpecialized(displayMetadata.EDID)
@@ -110,14 +207,14 @@ func mainEntrypoint(context.Context, *cli.Command) error {
 	}
 
 	log.Info("Uploading patched EDID firmware")
-	err = edidtools.LoadCustomEDIDFirmware(displayMetadata, patchedFirmware)
+	err = edidtools.LoadCustomEDIDFirmware(privilegedOperations, displayMetadata, patchedFirmware)
 
 	if err != nil {
 		return fmt.Errorf("failed to upload patched EDID firmware: %w", err)
 	}
 
 	atexit.Register(func() {
-		err := edidtools.UnloadCustomEDIDFirmware(displayMetadata)
+		err := edidtools.UnloadCustomEDIDFirmware(privilegedOperations, displayMetadata)
 
 		if err != nil {
 			log.Errorf("Failed to unload custom EDID firmware: %s", err.Error())
@@ -129,7 +226,42 @@ func mainEntrypoint(context.Context, *cli.Command) error {
 	fmt.Print("Press the Enter key to continue loading after you unplug and plug in your XR device.")
 	bufio.NewReader(os.Stdin).ReadBytes('\n') // Wait for Enter key press before continuing
 
+	var pointer *uinput.Pointer
+
+	if *config.Focus.PointerWarp == libconfig.PointerWarpUinput || *config.HeadPointer.Enabled {
+		pointer, err = openVirtualPointer(privilegedOperations)
+
+		if err != nil {
+			log.Warnf("Pointer warping and the head pointer are disabled, as the virtual pointer couldn't be created: %s", err.Error())
+		} else {
+			atexit.Register(func() {
+				pointer.Close()
+			})
+		}
+	}
+
+	// The glasses re-enumerate after being replugged above, so their nodes can only be opened now
+	xrDeviceNodes, err := openXRDeviceNodes(privilegedOperations)
+
+	if err != nil {
+		log.Errorf("Failed to open XR device: %s. Run `unrealxr setup` to give your user access to it", err.Error())
+		atexit.Exit(1)
+		return nil
+	}
+
+	atexit.Register(func() {
+		for _, node := range xrDeviceNodes {
+			node.Close()
+		}
+	})
+
 	log.Info("Initializing XR headset")
+
+	// Multisampling has to be requested before the window is created
+	if *config.Rendering.MSAA {
+		rl.SetConfigFlags(rl.FlagMsaa4xHint)
+	}
+
 	rl.SetTargetFPS(int32(displayMetadata.MaxRefreshRate))
 	rl.InitWindow(int32(displayMetadata.MaxWidth), int32(displayMetadata.MaxHeight), "UnrealXR")
 
@@ -147,38 +279,47 @@ func mainEntrypoint(context.Context, *cli.Command) error {
 
 	evdiCards := make([]*renderer.EvdiDisplayMetadata, *config.DisplayConfig.Count)
 
+	atexit.Register(func() {
+		// Every capture goroutine must be done with its node before we disconnect any of them
+		for _, evdiCard := range evdiCards {
+			if evdiCard != nil {
+				evdiCard.StopCapture()
+			}
+		}
+
+		for _, evdiCard := range evdiCards {
+			if evdiCard != nil {
+				evdiCard.EvdiNode.Disconnect()
+			}
+		}
+	})
+
 	for currentDisplay := range *config.DisplayConfig.Count {
 		openedDevice, err := libevdi.Open(nil)
 
 		if err != nil {
-			log.Errorf("Failed to open EVDI device: %s", err.Error())
-		}
+			// We likely don't have a free EVDI device, and can't add one without root
+			log.Debugf("Failed to open EVDI device, adding a new one: %s", err.Error())
 
-		openedDevice.Connect(displayMetadata.EDID, uint(displayMetadata.MaxWidth), uint(displayMetadata.MaxHeight), uint(displayMetadata.MaxRefreshRate))
+			if err := privilegedOperations.AddEvdiDevices(1); err != nil {
+				log.Errorf("Failed to add EVDI device: %s", err.Error())
+				atexit.Exit(1)
+				return nil
+			}
 
-		atexit.Register(func() {
-			openedDevice.Disconnect()
-		})
+			openedDevice, err = libevdi.Open(nil)
 
-		displayRect := &libevdi.EvdiDisplayRect{
-			X1: 0,
-			Y1: 0,
-			X2: displayMetadata.MaxWidth,
-			Y2: displayMetadata.MaxHeight,
+			if err != nil {
+				log.Errorf("Failed to open EVDI device: %s", err.Error())
+				atexit.Exit(1)
+				return nil
+			}
 		}
 
-		displayBuffer, err := openedDevice.CreateBuffer(displayMetadata.MaxWidth, displayMetadata.MaxHeight, libevdi.StridePixelFormatRGBA32, displayRect)
-
-		if err != nil {
-			log.Errorf("Failed to create buffer for display %d: %s", currentDisplay, err.Error())
-			atexit.Exit(1)
-			return nil
-		}
+		openedDevice.Connect(displayMetadata.EDID, uint(displayMetadata.MaxWidth), uint(displayMetadata.MaxHeight), uint(displayMetadata.MaxRefreshRate))
 
 		displayMetadata := &renderer.EvdiDisplayMetadata{
 			EvdiNode: openedDevice,
-			Rect:     displayRect,
-			Buffer:   displayBuffer,
 		}
 
 		displayMetadata.EventContext = &libevdi.EvdiEventContext{}
@@ -191,7 +332,7 @@ func mainEntrypoint(context.Context, *cli.Command) error {
 	time.Sleep(time.Millisecond * 100)
 
 	log.Info("Initialized displays. Entering rendering loop")
-	renderer.EnterRenderLoop(config, displayMetadata, evdiCards)
+	renderer.EnterRenderLoop(config, configDir, displayMetadata, evdiCards, pointer, xrDeviceNodes)
 
 	atexit.Exit(0)
 	return nil
@@ -221,12 +362,29 @@ func main() {
 
 	// Initialize the CLI
 	cmd := &cli.Command{
-		Name:   "unrealxr",
-		Usage:  "A spatial multi-display renderer for XR devices",
+		Name:  "unrealxr",
+		Usage: "A spatial multi-display renderer for XR devices",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "profile",
+				Usage: "profile from the config to use instead of the one set in it",
+			},
+		},
 		Action: mainEntrypoint,
+		Commands: []*cli.Command{
+			edidCommand,
+			setupCommand,
+		},
 	}
 
 	if err := cmd.Run(context.Background(), os.Args); err != nil {
+		exitCodeErr := &platformtools.ExitCodeError{}
+
+		// The elevated process has already reported its own errors
+		if errors.As(err, &exitCodeErr) {
+			os.Exit(exitCodeErr.ExitCode)
+		}
+
 		log.Fatalf("Fatal error during execution: %s", err.Error())
 	}
 }
@@ -0,0 +1,80 @@
+package platformtools
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Picks the first available privilege escalation backend
+const EscalationBackendAuto = "auto"
+
+type EscalationOptions struct {
+	// Name of the escalation backend to use ("pkexec", "run0", "sudo", "doas"), or EscalationBackendAuto
+	Backend string
+	// Names of the environment variables that are forwarded to the elevated process, if they're set. Display variables only go to the
+	// process in legacy mode, which opens the window as root
+	EnvironmentAllowlist []string
+}
+
+// Returned when an elevated process ran, but exited with a non-zero exit code
+type ExitCodeError struct {
+	ExitCode int
+}
+
+func (err *ExitCodeError) Error() string {
+	return fmt.Sprintf("elevated process exited with code %d", err.ExitCode)
+}
+
+// Largest config we're willing to pass to an elevated process
+const maxForwardedConfigSize = 1024 * 1024
+
+// Writes a config to the elevated process in the format ReadForwardedConfig expects: the length in decimal, a newline and the config itself
+func writeForwardedConfig(writer io.Writer, config []byte) error {
+	if len(config) > maxForwardedConfigSize {
+		return fmt.Errorf("config is too large to forward (%d bytes)", len(config))
+	}
+
+	if _, err := io.WriteString(writer, strconv.Itoa(len(config))+"\n"); err != nil {
+		return err
+	}
+
+	_, err := writer.Write(config)
+	return err
+}
+
+// Reads the config the unprivileged parent process passed to us. Reads exactly the config, so anything after it can still be read from reader
+func ReadForwardedConfig(reader io.Reader) ([]byte, error) {
+	header := []byte{}
+	character := make([]byte, 1)
+
+	for {
+		if _, err := io.ReadFull(reader, character); err != nil {
+			return nil, fmt.Errorf("failed to read config length: %w", err)
+		}
+
+		if character[0] == '\n' {
+			break
+		}
+
+		if character[0] < '0' || character[0] > '9' || len(header) >= 8 {
+			return nil, fmt.Errorf("malformed config length")
+		}
+
+		header = append(header, character[0])
+	}
+
+	length, err := strconv.Atoi(string(header))
+
+	if err != nil || length > maxForwardedConfigSize {
+		return nil, fmt.Errorf("invalid config length '%s'", string(header))
+	}
+
+	config := make([]byte, length)
+
+	if _, err := io.ReadFull(reader, config); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	return config, nil
+}
@@ -4,80 +4,248 @@
 package platformtools
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path"
+	"slices"
 )
 
-// Checks if we're in a Nix shell (all Linux OSes), or if we're in a NixOS system
-func isNixLikeEnvironment() bool {
-	if os.Getenv("IN_NIX_SHELL") != "" {
-		return true
-	}
+// Tool used to run commands as root
+type escalationBackend struct {
+	// Name of the backend, as used in the config file
+	name string
+	// Checks if the backend is usable on this machine
+	isAvailable func() bool
+	// Arguments placed before /usr/bin/env and the command to run
+	args []string
+}
+
+// In order of preference for auto-detection
+var escalationBackends = []*escalationBackend{
+	{
+		name:        "pkexec",
+		isAvailable: func() bool { return hasExecutable("pkexec") && hasPolkit() },
+		args:        []string{"pkexec", "--keep-cwd"},
+	},
+	{
+		name:        "run0",
+		isAvailable: func() bool { return hasExecutable("run0") && hasPolkit() },
+		args:        []string{"run0"},
+	},
+	{
+		name:        "sudo",
+		isAvailable: func() bool { return hasExecutable("sudo") },
+		args:        []string{"sudo"},
+	},
+	{
+		name:        "doas",
+		isAvailable: func() bool { return hasExecutable("doas") },
+		args:        []string{"doas"},
+	},
+}
 
-	_, err := os.Stat("/etc/nixos")
+func hasExecutable(name string) bool {
+	_, err := exec.LookPath(name)
 	return err == nil
 }
 
-// Attempts to do built in privilege escalation to admin
-func PrivilegeEscalate(configDir string) error {
-	executablePath, err := os.Executable()
+// Checks if polkit is installed, which both pkexec and run0 rely on
+func hasPolkit() bool {
+	for _, polkitPath := range []string{"/usr/share/polkit-1", "/etc/polkit-1", "/run/current-system/sw/share/polkit-1"} {
+		if _, err := os.Stat(polkitPath); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Gets the escalation backend with the given name, or auto-detects one if the name is "auto"
+func findEscalationBackend(name string) (*escalationBackend, error) {
+	for _, backend := range escalationBackends {
+		if name != EscalationBackendAuto && backend.name != name {
+			continue
+		}
+
+		if !backend.isAvailable() {
+			if name == EscalationBackendAuto {
+				continue
+			}
+
+			return nil, fmt.Errorf("privilege escalation backend '%s' is not available on this system", name)
+		}
+
+		return backend, nil
+	}
+
+	if name == EscalationBackendAuto {
+		return nil, fmt.Errorf("could not find a privilege escalation backend (tried pkexec, run0, sudo and doas)")
+	}
+
+	return nil, fmt.Errorf("unknown privilege escalation backend '%s'", name)
+}
+
+// PATH of elevated processes. The user's PATH is never forwarded, as it could point root at the user's binaries. Also covers NixOS, where
+// the system binaries live in /run/current-system
+const elevatedPath = "/run/wrappers/bin:/run/current-system/sw/bin:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// Environment variables that are only forwarded to processes that open windows (see forwardedEnvironment)
+var displayEnvironmentVariables = []string{"WAYLAND_DISPLAY", "DISPLAY", "XAUTHORITY"}
+
+// Builds the environment to forward to the elevated process from the allowlist. Only variables that are actually set are forwarded, and the display
+// variables only if forwardDisplay is set. PATH is always set to elevatedPath
+func forwardedEnvironment(allowlist []string, forwardDisplay bool) []string {
+	environment := []string{"PATH=" + elevatedPath}
+
+	for _, variable := range allowlist {
+		if !forwardDisplay && slices.Contains(displayEnvironmentVariables, variable) {
+			continue
+		}
+
+		value, ok := os.LookupEnv(variable)
+
+		if !ok {
+			continue
+		}
+
+		// Root has a different XDG_RUNTIME_DIR, so the socket path needs to be absolute
+		if variable == "WAYLAND_DISPLAY" && !path.IsAbs(value) {
+			value = path.Join(os.Getenv("XDG_RUNTIME_DIR"), value)
+		}
+
+		environment = append(environment, variable+"="+value)
+	}
+
+	return environment
+}
+
+// Builds a command that runs argv as root using the configured backend. forwardDisplay must only be set if the command opens windows
+func elevatedCommand(options *EscalationOptions, forwardDisplay bool, extraEnvironment []string, argv ...string) (*exec.Cmd, error) {
+	backend, err := findEscalationBackend(options.Backend)
 
 	if err != nil {
-		return fmt.Errorf("could not find own executable path")
+		return nil, err
 	}
 
-	logLevel := os.Getenv("UNREALXR_LOG_LEVEL")
-	systemPath := os.Getenv("PATH")
-	libraryPath := os.Getenv("LD_LIBRARY_PATH")
-	waylandDisplay := path.Join(os.Getenv("XDG_RUNTIME_DIR"), os.Getenv("WAYLAND_DISPLAY"))
-	rootXDGRuntimeDir := "/run/user/0"
+	args := append([]string{}, backend.args...)
+	args = append(args, "/usr/bin/env")
+	args = append(args, forwardedEnvironment(options.EnvironmentAllowlist, forwardDisplay)...)
+	args = append(args, extraEnvironment...)
+	args = append(args, argv...)
+
+	return exec.Command(args[0], args[1:]...), nil
+}
 
-	var command *exec.Cmd
+// Attempts to do built in privilege escalation to admin by re-running ourselves as root. Returns once the elevated process exits.
+//
+// The already validated config is passed to the elevated process over stdin (see ReadForwardedConfig), so it never has to read the user's config directory.
+func PrivilegeEscalate(options *EscalationOptions, config []byte) error {
+	executablePath, err := os.Executable()
 
-	if isNixLikeEnvironment() {
-		command = exec.Command(
-			"pkexec",
-			"--keep-cwd",
-			"/usr/bin/env",
-			"UXR_HAS_PRIVESC=1",
-			"UNREALXR_LOG_LEVEL="+logLevel,
-			"UNREALXR_CONFIG_PATH="+configDir,
-			"WAYLAND_DISPLAY="+waylandDisplay,
-			"XDG_RUNTIME_DIR="+rootXDGRuntimeDir,
-			"LD_LIBRARY_PATH="+libraryPath,
-			"PATH="+systemPath,
-			executablePath,
-		)
-	} else {
-		command = exec.Command(
-			"pkexec",
-			"--keep-cwd",
-			"/usr/bin/env",
+	if err != nil {
+		return fmt.Errorf("could not find own executable path: %w", err)
+	}
+
+	command, err := elevatedCommand(
+		options,
+		true,
+		[]string{
 			"UXR_HAS_PRIVESC=1",
-			"UNREALXR_LOG_LEVEL="+logLevel,
-			"UNREALXR_CONFIG_PATH="+configDir,
-			"WAYLAND_DISPLAY="+waylandDisplay,
-			"XDG_RUNTIME_DIR="+rootXDGRuntimeDir,
-			executablePath,
-		)
+			"UXR_CONFIG_ON_STDIN=1",
+			"XDG_RUNTIME_DIR=/run/user/0",
+		},
+		executablePath,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	stdin, err := command.StdinPipe()
+
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	if err := command.Start(); err != nil {
+		return fmt.Errorf("failed to execute elevated process: %w", err)
+	}
+
+	if err := writeForwardedConfig(stdin, config); err != nil {
+		command.Process.Kill()
+		command.Wait()
+
+		return fmt.Errorf("failed to pass config to elevated process: %w", err)
+	}
+
+	// Our own stdin goes to the elevated process after the config
+	go func() {
+		io.Copy(stdin, os.Stdin)
+		stdin.Close()
+	}()
+
+	err = command.Wait()
+
+	if err != nil {
+		exitErr := &exec.ExitError{}
+
+		if errors.As(err, &exitErr) {
+			return &ExitCodeError{ExitCode: exitErr.ExitCode()}
+		}
+
+		return fmt.Errorf("failed to execute elevated process: %w", err)
 	}
 
-	command.Stdin = os.Stdin
+	return nil
+}
+
+// Starts the privileged helper as root without waiting for it to exit
+func StartPrivilegedHelper(options *EscalationOptions, helperPath string, args ...string) (*exec.Cmd, error) {
+	command, err := elevatedCommand(options, false, nil, append([]string{helperPath}, args...)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	command.Stdin = nil
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	if err := command.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start privileged helper: %w", err)
+	}
+
+	return command, nil
+}
+
+// Runs a command as root and waits for it to exit. stdin is passed to the command
+func RunElevated(options *EscalationOptions, stdin io.Reader, argv ...string) error {
+	command, err := elevatedCommand(options, false, nil, argv...)
+
+	if err != nil {
+		return err
+	}
+
+	command.Stdin = stdin
 	command.Stdout = os.Stdout
 	command.Stderr = os.Stderr
 
 	err = command.Run()
 
 	if err != nil {
-		exitErr, ok := err.(*exec.ExitError)
+		exitErr := &exec.ExitError{}
 
-		if !ok {
-			return fmt.Errorf("failed to execute command, and failed to typecast err to ExitError")
+		if errors.As(err, &exitErr) {
+			return &ExitCodeError{ExitCode: exitErr.ExitCode()}
 		}
 
-		os.Exit(exitErr.ExitCode())
+		return fmt.Errorf("failed to execute elevated command: %w", err)
 	}
 
 	return nil
@@ -3,9 +3,23 @@
 
 package platformtools
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os/exec"
+)
 
-// Attempts to do built in privilege escalation to admin
-func PrivilegeEscalate(configDir string) error {
+// Attempts to do built in privilege escalation to admin by re-running ourselves as root. Returns once the elevated process exits
+func PrivilegeEscalate(options *EscalationOptions, config []byte) error {
+	return fmt.Errorf("privilege escalation not implemented on macOS")
+}
+
+// Starts the privileged helper as root without waiting for it to exit
+func StartPrivilegedHelper(options *EscalationOptions, helperPath string, args ...string) (*exec.Cmd, error) {
+	return nil, fmt.Errorf("privilege escalation not implemented on macOS")
+}
+
+// Runs a command as root and waits for it to exit. stdin is passed to the command
+func RunElevated(options *EscalationOptions, stdin io.Reader, argv ...string) error {
 	return fmt.Errorf("privilege escalation not implemented on macOS")
 }
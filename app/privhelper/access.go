@@ -0,0 +1,11 @@
+package privhelper
+
+// A node that UnrealXR needs, but can't access without root (see CheckAccess)
+type AccessProblem struct {
+	// Path of the inaccessible node
+	Path string
+	// What the node is used for
+	Description string
+	// Whether `unrealxr setup` can fix this with udev rules
+	FixableWithUdev bool
+}
@@ -0,0 +1,173 @@
+//go:build linux
+// +build linux
+
+package privhelper
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"git.lunr.sh/UnrealXR/unrealxr/ardriver/xreal"
+	"golang.org/x/sys/unix"
+)
+
+func isReadWritable(nodePath string) bool {
+	return unix.Access(nodePath, unix.R_OK|unix.W_OK) == nil
+}
+
+// Finds the /dev/bus/usb nodes of all connected Xreal devices (used by hidapi-libusb)
+func xrealUSBNodes() []string {
+	nodes := []string{}
+	usbDevices, err := os.ReadDir("/sys/bus/usb/devices")
+
+	if err != nil {
+		return nodes
+	}
+
+	for _, usbDevice := range usbDevices {
+		devicePath := path.Join("/sys/bus/usb/devices", usbDevice.Name())
+
+		vendorID, vendorErr := readSysfsHex(path.Join(devicePath, "idVendor"))
+		productID, productErr := readSysfsHex(path.Join(devicePath, "idProduct"))
+
+		if vendorErr != nil || productErr != nil || !xreal.IsSupportedDevice(vendorID, productID) {
+			continue
+		}
+
+		busNumber, busErr := os.ReadFile(path.Join(devicePath, "busnum"))
+		deviceNumber, deviceErr := os.ReadFile(path.Join(devicePath, "devnum"))
+
+		if busErr != nil || deviceErr != nil {
+			continue
+		}
+
+		bus, busErr := strconv.Atoi(strings.TrimSpace(string(busNumber)))
+		device, deviceErr := strconv.Atoi(strings.TrimSpace(string(deviceNumber)))
+
+		if busErr != nil || deviceErr != nil {
+			continue
+		}
+
+		nodes = append(nodes, fmt.Sprintf("/dev/bus/usb/%03d/%03d", bus, device))
+	}
+
+	return nodes
+}
+
+// Finds the hidraw nodes of all connected supported XR devices
+func XRDeviceHIDRawNodes() []string {
+	nodes := []string{}
+	hidRawDevices, err := os.ReadDir("/sys/class/hidraw")
+
+	if err != nil {
+		return nodes
+	}
+
+	for _, hidRawDevice := range hidRawDevices {
+		vendorID, productID, err := hidRawDeviceIDs(hidRawDevice.Name())
+
+		if err != nil || !xreal.IsSupportedDevice(vendorID, productID) {
+			continue
+		}
+
+		nodes = append(nodes, "/dev/"+hidRawDevice.Name())
+	}
+
+	return nodes
+}
+
+// Finds the DRM card nodes of all EVDI devices
+func evdiCardNodes() []string {
+	nodes := []string{}
+	drmDevices, err := os.ReadDir("/sys/class/drm")
+
+	if err != nil {
+		return nodes
+	}
+
+	for _, drmDevice := range drmDevices {
+		if !drmCardPattern.MatchString(drmDevice.Name()) {
+			continue
+		}
+
+		driver, err := os.Readlink(path.Join("/sys/class/drm", drmDevice.Name(), "device", "driver"))
+
+		if err != nil || path.Base(driver) != "evdi" {
+			continue
+		}
+
+		nodes = append(nodes, "/dev/dri/"+drmDevice.Name())
+	}
+
+	return nodes
+}
+
+func readSysfsHex(sysfsPath string) (uint16, error) {
+	contents, err := os.ReadFile(sysfsPath)
+
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := strconv.ParseUint(strings.TrimSpace(string(contents)), 16, 16)
+	return uint16(value), err
+}
+
+// Checks which of the nodes UnrealXR needs are inaccessible to the current user. If nothing is returned, no privilege escalation is needed.
+//
+// The EDID override of the given DRM connector is only checked if card and connector are set.
+func CheckAccess(card, connector string) []*AccessProblem {
+	problems := []*AccessProblem{}
+
+	for _, node := range XRDeviceHIDRawNodes() {
+		if !isReadWritable(node) {
+			problems = append(problems, &AccessProblem{
+				Path:            node,
+				Description:     "XR device sensors (hidraw)",
+				FixableWithUdev: true,
+			})
+		}
+	}
+
+	for _, node := range xrealUSBNodes() {
+		if !isReadWritable(node) {
+			problems = append(problems, &AccessProblem{
+				Path:            node,
+				Description:     "XR device sensors (USB)",
+				FixableWithUdev: true,
+			})
+		}
+	}
+
+	for _, node := range evdiCardNodes() {
+		if !isReadWritable(node) {
+			problems = append(problems, &AccessProblem{
+				Path:            node,
+				Description:     "EVDI virtual display",
+				FixableWithUdev: true,
+			})
+		}
+	}
+
+	if unix.Access(evdiAddPath, unix.W_OK) != nil {
+		problems = append(problems, &AccessProblem{
+			Path:            evdiAddPath,
+			Description:     "adding EVDI virtual displays",
+			FixableWithUdev: true,
+		})
+	}
+
+	if card != "" && connector != "" {
+		if unix.Access(EDIDOverridePath(card, connector), unix.W_OK) != nil {
+			problems = append(problems, &AccessProblem{
+				Path:        EDIDOverridePath(card, connector),
+				Description: "EDID override (debugfs)",
+			})
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,203 @@
+//go:build linux
+// +build linux
+
+package privhelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Talks to a running privileged helper (see Serve).
+//
+// Implements Operations
+type Client struct {
+	conn *net.UnixConn
+	lock sync.Mutex
+}
+
+// Connects to the privileged helper listening on socketPath. Retries until the helper is up or ctx is done, as the helper may still be waiting on authentication.
+func Dial(ctx context.Context, socketPath string) (*Client, error) {
+	for {
+		conn, err := net.DialUnix("unixpacket", nil, &net.UnixAddr{Name: socketPath, Net: "unixpacket"})
+
+		if err == nil {
+			client := &Client{
+				conn: conn,
+			}
+
+			if _, err := client.request(&Request{Operation: OperationHello}); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("handshake with privileged helper failed: %w", err)
+			}
+
+			return client, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to connect to privileged helper: %w", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Sends a request and waits for the response. Returns a file if the helper passed one along with the response.
+func (client *Client) request(request *Request) (*os.File, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	request.Version = ProtocolVersion
+	message, err := json.Marshal(request)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	if len(message) > MaxMessageSize {
+		return nil, fmt.Errorf("request is too large (%d bytes)", len(message))
+	}
+
+	if _, _, err := client.conn.WriteMsgUnix(message, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	responseBuffer := make([]byte, MaxMessageSize)
+	oobBuffer := make([]byte, syscall.CmsgSpace(4))
+
+	responseLength, oobLength, _, _, err := client.conn.ReadMsgUnix(responseBuffer, oobBuffer)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if responseLength == 0 {
+		return nil, fmt.Errorf("privileged helper closed the connection")
+	}
+
+	var file *os.File
+
+	if oobLength != 0 {
+		controlMessages, err := syscall.ParseSocketControlMessage(oobBuffer[:oobLength])
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse control message: %w", err)
+		}
+
+		for _, controlMessage := range controlMessages {
+			fds, err := syscall.ParseUnixRights(&controlMessage)
+
+			if err != nil {
+				continue
+			}
+
+			for _, fd := range fds {
+				if file == nil {
+					name := request.Path
+
+					if name == "" {
+						name = request.Operation
+					}
+
+					file = os.NewFile(uintptr(fd), name)
+				} else {
+					syscall.Close(fd)
+				}
+			}
+		}
+	}
+
+	response := &Response{}
+
+	if err := json.Unmarshal(responseBuffer[:responseLength], response); err != nil {
+		if file != nil {
+			file.Close()
+		}
+
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if !response.OK {
+		if file != nil {
+			file.Close()
+		}
+
+		return nil, fmt.Errorf("privileged helper: %s", response.Error)
+	}
+
+	return file, nil
+}
+
+func (client *Client) WriteEDIDOverride(card, connector string, edid []byte) error {
+	_, err := client.request(&Request{
+		Operation: OperationWriteEDIDOverride,
+		Card:      card,
+		Connector: connector,
+		EDID:      edid,
+	})
+
+	return err
+}
+
+func (client *Client) ResetEDIDOverride(card, connector string) error {
+	_, err := client.request(&Request{
+		Operation: OperationResetEDIDOverride,
+		Card:      card,
+		Connector: connector,
+	})
+
+	return err
+}
+
+func (client *Client) AddEvdiDevices(count int) error {
+	_, err := client.request(&Request{
+		Operation: OperationAddEvdiDevices,
+		Count:     count,
+	})
+
+	return err
+}
+
+func (client *Client) OpenHIDRaw(path string) (*os.File, error) {
+	file, err := client.request(&Request{
+		Operation: OperationOpenHIDRaw,
+		Path:      path,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if file == nil {
+		return nil, fmt.Errorf("privileged helper did not send a file descriptor for '%s'", path)
+	}
+
+	return file, nil
+}
+
+func (client *Client) CreateVirtualPointer() (*os.File, error) {
+	file, err := client.request(&Request{
+		Operation: OperationCreateVirtualPointer,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if file == nil {
+		return nil, fmt.Errorf("privileged helper did not send a file descriptor for the virtual pointer")
+	}
+
+	return file, nil
+}
+
+// Closes the connection, which also makes the privileged helper exit.
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
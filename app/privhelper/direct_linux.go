@@ -0,0 +1,202 @@
+//go:build linux
+// +build linux
+
+package privhelper
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/uinput"
+	"git.lunr.sh/UnrealXR/unrealxr/ardriver/xreal"
+)
+
+const evdiAddPath = "/sys/devices/evdi/add"
+
+const uinputPath = "/dev/uinput"
+
+// Name of the virtual mouse created through uinput
+const virtualPointerName = "UnrealXR virtual pointer"
+
+// Performs privileged operations directly. This only works if we're running as root, or if every required node is accessible to us.
+//
+// Implements Operations
+type DirectOperations struct{}
+
+func NewDirectOperations() *DirectOperations {
+	return &DirectOperations{}
+}
+
+// Gets the debugfs EDID override path of a DRM connector.
+func EDIDOverridePath(card, connector string) string {
+	return "/sys/kernel/debug/dri/" + strings.Replace(card, "card", "", 1) + "/" + connector + "/edid_override"
+}
+
+// Opens a kernel interface node without following symlinks, and checks that it is owned by root and of the expected file type.
+// This makes sure a privileged process can't be tricked into writing to anything else.
+func openKernelNode(nodePath string, flags int, expectedType os.FileMode) (*os.File, error) {
+	file, err := os.OpenFile(nodePath, flags|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if info.Mode().Type() != expectedType {
+		file.Close()
+		return nil, fmt.Errorf("'%s' has unexpected file type '%s'", nodePath, info.Mode().Type())
+	}
+
+	if stat, ok := info.Sys().(*syscall.Stat_t); !ok || stat.Uid != 0 {
+		file.Close()
+		return nil, fmt.Errorf("'%s' is not owned by root", nodePath)
+	}
+
+	return file, nil
+}
+
+func writeEDIDOverride(card, connector string, data []byte) error {
+	if err := validateDRMConnector(card, connector); err != nil {
+		return err
+	}
+
+	drmFile, err := openKernelNode(EDIDOverridePath(card, connector), os.O_WRONLY, 0)
+
+	if err != nil {
+		return fmt.Errorf("failed to open EDID override file for monitor '%s': %w", connector, err)
+	}
+
+	defer drmFile.Close()
+
+	if _, err := drmFile.Write(data); err != nil {
+		return fmt.Errorf("failed to write EDID override for monitor '%s': %w", connector, err)
+	}
+
+	return nil
+}
+
+func (ops *DirectOperations) WriteEDIDOverride(card, connector string, edid []byte) error {
+	return writeEDIDOverride(card, connector, edid)
+}
+
+func (ops *DirectOperations) ResetEDIDOverride(card, connector string) error {
+	return writeEDIDOverride(card, connector, []byte("reset"))
+}
+
+func (ops *DirectOperations) AddEvdiDevices(count int) error {
+	if count < 1 || count > MaxEvdiDevicesPerRequest {
+		return fmt.Errorf("EVDI device count must be between 1 and %d", MaxEvdiDevicesPerRequest)
+	}
+
+	addFile, err := openKernelNode(evdiAddPath, os.O_WRONLY, 0)
+
+	if err != nil {
+		return fmt.Errorf("failed to open EVDI add interface: %w", err)
+	}
+
+	defer addFile.Close()
+
+	if _, err := addFile.Write([]byte(strconv.Itoa(count))); err != nil {
+		return fmt.Errorf("failed to add EVDI devices: %w", err)
+	}
+
+	return nil
+}
+
+func (ops *DirectOperations) OpenHIDRaw(devicePath string) (*os.File, error) {
+	if !hidRawPathPattern.MatchString(devicePath) {
+		return nil, fmt.Errorf("invalid hidraw path '%s'", devicePath)
+	}
+
+	vendorID, productID, err := hidRawDeviceIDs(path.Base(devicePath))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !xreal.IsSupportedDevice(vendorID, productID) {
+		return nil, fmt.Errorf("'%s' is not a supported XR device (%04x:%04x)", devicePath, vendorID, productID)
+	}
+
+	file, err := openKernelNode(devicePath, os.O_RDWR, os.ModeDevice|os.ModeCharDevice)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open '%s': %w", devicePath, err)
+	}
+
+	return file, nil
+}
+
+func (ops *DirectOperations) CreateVirtualPointer() (*os.File, error) {
+	file, err := openKernelNode(uinputPath, os.O_WRONLY|syscall.O_NONBLOCK, os.ModeDevice|os.ModeCharDevice)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open '%s': %w", uinputPath, err)
+	}
+
+	// Set up the device before the file leaves us, so it can't be used to create anything but this pointer
+	if err := uinput.CreatePointerDevice(file, virtualPointerName); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (ops *DirectOperations) Close() error {
+	return nil
+}
+
+// Reads the USB vendor and product ID of a hidraw node from sysfs.
+func hidRawDeviceIDs(hidRawName string) (uint16, uint16, error) {
+	uevent, err := os.Open("/sys/class/hidraw/" + hidRawName + "/device/uevent")
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read device information for '%s': %w", hidRawName, err)
+	}
+
+	defer uevent.Close()
+	scanner := bufio.NewScanner(uevent)
+
+	for scanner.Scan() {
+		hidID, ok := strings.CutPrefix(scanner.Text(), "HID_ID=")
+
+		if !ok {
+			continue
+		}
+
+		// Format: <bus>:<vendor>:<product>, all in hexadecimal
+		parts := strings.Split(hidID, ":")
+
+		if len(parts) != 3 {
+			return 0, 0, fmt.Errorf("malformed HID_ID '%s' for '%s'", hidID, hidRawName)
+		}
+
+		vendorID, err := strconv.ParseUint(parts[1], 16, 16)
+
+		if err != nil {
+			return 0, 0, fmt.Errorf("malformed vendor ID for '%s': %w", hidRawName, err)
+		}
+
+		productID, err := strconv.ParseUint(parts[2], 16, 16)
+
+		if err != nil {
+			return 0, 0, fmt.Errorf("malformed product ID for '%s': %w", hidRawName, err)
+		}
+
+		return uint16(vendorID), uint16(productID), nil
+	}
+
+	return 0, 0, fmt.Errorf("could not find HID_ID for '%s'", hidRawName)
+}
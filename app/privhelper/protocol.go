@@ -0,0 +1,166 @@
+package privhelper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	"git.lunr.sh/UnrealXR/unrealxr/edidpatcher"
+)
+
+// Version of the protocol spoken between the app and the privileged helper. Bump this on any incompatible change.
+const ProtocolVersion = 5
+
+// Maximum size of a single protocol message (in bytes).
+const MaxMessageSize = 64 * 1024
+
+// Maximum amount of EVDI devices that can be added with a single request.
+const MaxEvdiDevicesPerRequest = 16
+
+// Operations supported by the privileged helper
+const (
+	OperationHello                = "hello"
+	OperationWriteEDIDOverride    = "write_edid_override"
+	OperationResetEDIDOverride    = "reset_edid_override"
+	OperationAddEvdiDevices       = "add_evdi_devices"
+	OperationOpenHIDRaw           = "open_hidraw"
+	OperationCreateVirtualPointer = "create_virtual_pointer"
+)
+
+var (
+	drmCardPattern      = regexp.MustCompile(`^card[0-9]{1,3}$`)
+	drmConnectorPattern = regexp.MustCompile(`^[A-Za-z]{1,16}(-[A-Za-z]{1,16})?-[0-9]{1,3}$`)
+	hidRawPathPattern   = regexp.MustCompile(`^/dev/hidraw[0-9]{1,3}$`)
+)
+
+// Root-only operations that UnrealXR needs. Implemented by the helper client (see Client) and by DirectOperations.
+type Operations interface {
+	// Writes a (patched) EDID into the debugfs EDID override of a DRM connector.
+	WriteEDIDOverride(card, connector string, edid []byte) error
+	// Resets the debugfs EDID override of a DRM connector.
+	ResetEDIDOverride(card, connector string) error
+	// Adds EVDI devices through the EVDI sysfs interface.
+	AddEvdiDevices(count int) error
+	// Opens a hidraw node of a supported XR device for reading and writing.
+	OpenHIDRaw(path string) (*os.File, error)
+	// Creates a virtual mouse through uinput. The returned file can only be used to send pointer events to it.
+	CreateVirtualPointer() (*os.File, error)
+	// Releases any resources held by the implementation.
+	Close() error
+}
+
+type Request struct {
+	Version   int    `json:"version"`
+	Operation string `json:"op"`
+	Card      string `json:"card,omitempty"`
+	Connector string `json:"connector,omitempty"`
+	EDID      []byte `json:"edid,omitempty"`
+	Count     int    `json:"count,omitempty"`
+	Path      string `json:"path,omitempty"`
+}
+
+type Response struct {
+	Version int    `json:"version"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Decodes a request, rejecting unknown fields and trailing data.
+func DecodeRequest(message []byte) (*Request, error) {
+	if len(message) > MaxMessageSize {
+		return nil, fmt.Errorf("message is too large (%d bytes)", len(message))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.DisallowUnknownFields()
+
+	request := &Request{}
+
+	if err := decoder.Decode(request); err != nil {
+		return nil, fmt.Errorf("failed to decode request: %w", err)
+	}
+
+	if decoder.More() {
+		return nil, fmt.Errorf("trailing data after request")
+	}
+
+	return request, nil
+}
+
+// Strictly validates a request. Every operation must only carry the fields it uses, and all fields must be well-formed.
+func (request *Request) Validate() error {
+	if request.Version != ProtocolVersion {
+		return fmt.Errorf("unsupported protocol version %d (expected %d)", request.Version, ProtocolVersion)
+	}
+
+	hasCard := request.Card != ""
+	hasConnector := request.Connector != ""
+	hasEDID := len(request.EDID) != 0
+	hasCount := request.Count != 0
+	hasPath := request.Path != ""
+
+	switch request.Operation {
+	case OperationHello, OperationCreateVirtualPointer:
+		if hasCard || hasConnector || hasEDID || hasCount || hasPath {
+			return fmt.Errorf("unexpected fields for '%s'", request.Operation)
+		}
+
+	case OperationWriteEDIDOverride, OperationResetEDIDOverride:
+		if hasCount || hasPath {
+			return fmt.Errorf("unexpected fields for '%s'", request.Operation)
+		}
+
+		if err := validateDRMConnector(request.Card, request.Connector); err != nil {
+			return err
+		}
+
+		if request.Operation == OperationResetEDIDOverride {
+			if hasEDID {
+				return fmt.Errorf("unexpected EDID for '%s'", request.Operation)
+			}
+
+			break
+		}
+
+		if err := edidpatcher.VerifyStructure(request.EDID); err != nil {
+			return fmt.Errorf("invalid EDID: %w", err)
+		}
+
+	case OperationAddEvdiDevices:
+		if hasCard || hasConnector || hasEDID || hasPath {
+			return fmt.Errorf("unexpected fields for '%s'", request.Operation)
+		}
+
+		if request.Count < 1 || request.Count > MaxEvdiDevicesPerRequest {
+			return fmt.Errorf("EVDI device count must be between 1 and %d", MaxEvdiDevicesPerRequest)
+		}
+
+	case OperationOpenHIDRaw:
+		if hasCard || hasConnector || hasEDID || hasCount {
+			return fmt.Errorf("unexpected fields for '%s'", request.Operation)
+		}
+
+		if !hidRawPathPattern.MatchString(request.Path) {
+			return fmt.Errorf("invalid hidraw path '%s'", request.Path)
+		}
+
+	default:
+		return fmt.Errorf("unknown operation '%s'", request.Operation)
+	}
+
+	return nil
+}
+
+func validateDRMConnector(card, connector string) error {
+	if !drmCardPattern.MatchString(card) {
+		return fmt.Errorf("invalid DRM card '%s'", card)
+	}
+
+	if !drmConnectorPattern.MatchString(connector) {
+		return fmt.Errorf("invalid DRM connector '%s'", connector)
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+//go:build linux
+// +build linux
+
+package privhelper
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"syscall"
+	"time"
+)
+
+// How long the helper waits for the app to connect before giving up.
+const acceptTimeout = 30 * time.Second
+
+// Listens on socketPath and serves privileged operations for a single client connection owned by clientUID. Returns once that client disconnects.
+//
+// The socket is created with mode 0600 and owned by clientUID, and the peer credentials of the connecting process are checked against clientUID.
+func Serve(socketPath string, clientUID int, logger func(format string, args ...any)) error {
+	if !path.IsAbs(socketPath) {
+		return fmt.Errorf("socket path must be absolute")
+	}
+
+	socketDir := path.Dir(socketPath)
+	socketDirInfo, err := os.Lstat(socketDir)
+
+	if err != nil {
+		return fmt.Errorf("failed to stat socket directory: %w", err)
+	}
+
+	if !socketDirInfo.IsDir() {
+		return fmt.Errorf("socket directory '%s' is not a directory", socketDir)
+	}
+
+	socketDirStat, ok := socketDirInfo.Sys().(*syscall.Stat_t)
+
+	if !ok || int(socketDirStat.Uid) != clientUID || socketDirInfo.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("socket directory '%s' must be owned by UID %d and only accessible by its owner", socketDir, clientUID)
+	}
+
+	// The socket is created with the right mode straight away. Changing it afterwards would follow a symlink the client could put in its place
+	oldUmask := syscall.Umask(0o177)
+	listener, err := net.ListenUnix("unixpacket", &net.UnixAddr{Name: socketPath, Net: "unixpacket"})
+	syscall.Umask(oldUmask)
+
+	if err != nil {
+		return fmt.Errorf("failed to listen on '%s': %w", socketPath, err)
+	}
+
+	// We remove the socket ourselves once we have our client, so nobody else can connect afterwards
+	listener.SetUnlinkOnClose(false)
+	defer listener.Close()
+	defer os.Remove(socketPath)
+
+	if err := os.Lchown(socketPath, clientUID, -1); err != nil {
+		return fmt.Errorf("failed to change owner of socket: %w", err)
+	}
+
+	if err := listener.SetDeadline(time.Now().Add(acceptTimeout)); err != nil {
+		return fmt.Errorf("failed to set accept deadline: %w", err)
+	}
+
+	conn, err := listener.AcceptUnix()
+
+	if err != nil {
+		return fmt.Errorf("failed to accept client: %w", err)
+	}
+
+	defer conn.Close()
+
+	listener.Close()
+	os.Remove(socketPath)
+
+	peerUID, err := peerCredentialsUID(conn)
+
+	if err != nil {
+		return err
+	}
+
+	if peerUID != clientUID {
+		return fmt.Errorf("rejecting client with UID %d (expected %d)", peerUID, clientUID)
+	}
+
+	logger("Client connected")
+
+	ops := NewDirectOperations()
+	messageBuffer := make([]byte, MaxMessageSize+1)
+	hasSaidHello := false
+
+	for {
+		messageLength, _, _, _, err := conn.ReadMsgUnix(messageBuffer, nil)
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to read request: %w", err)
+		}
+
+		// SOCK_SEQPACKET sends a zero length message when the client hangs up
+		if messageLength == 0 {
+			return nil
+		}
+
+		request, err := DecodeRequest(messageBuffer[:messageLength])
+
+		if err == nil {
+			err = request.Validate()
+		}
+
+		if err == nil && !hasSaidHello && request.Operation != OperationHello {
+			err = fmt.Errorf("expected '%s' as the first request", OperationHello)
+		}
+
+		if err != nil {
+			logger("Rejected request: %s", err.Error())
+
+			// Invalid requests terminate the session. A well-behaved client never sends them
+			writeResponse(conn, err, nil)
+			return fmt.Errorf("client sent an invalid request: %w", err)
+		}
+
+		logger("Handling '%s'", request.Operation)
+
+		var fileToSend *os.File
+
+		switch request.Operation {
+		case OperationHello:
+			hasSaidHello = true
+
+		case OperationWriteEDIDOverride:
+			err = ops.WriteEDIDOverride(request.Card, request.Connector, request.EDID)
+
+		case OperationResetEDIDOverride:
+			err = ops.ResetEDIDOverride(request.Card, request.Connector)
+
+		case OperationAddEvdiDevices:
+			err = ops.AddEvdiDevices(request.Count)
+
+		case OperationOpenHIDRaw:
+			fileToSend, err = ops.OpenHIDRaw(request.Path)
+
+		case OperationCreateVirtualPointer:
+			fileToSend, err = ops.CreateVirtualPointer()
+		}
+
+		if err != nil {
+			logger("'%s' failed: %s", request.Operation, err.Error())
+		}
+
+		err = writeResponse(conn, err, fileToSend)
+
+		if fileToSend != nil {
+			fileToSend.Close()
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeResponse(conn *net.UnixConn, operationErr error, fileToSend *os.File) error {
+	response := &Response{
+		Version: ProtocolVersion,
+		OK:      operationErr == nil,
+	}
+
+	if operationErr != nil {
+		response.Error = operationErr.Error()
+	}
+
+	message, err := json.Marshal(response)
+
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	var rights []byte
+
+	if fileToSend != nil {
+		rights = syscall.UnixRights(int(fileToSend.Fd()))
+	}
+
+	if _, _, err := conn.WriteMsgUnix(message, rights, nil); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+
+	return nil
+}
+
+func peerCredentialsUID(conn *net.UnixConn) (int, error) {
+	rawConn, err := conn.SyscallConn()
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to get raw connection: %w", err)
+	}
+
+	var (
+		credentials *syscall.Ucred
+		credErr     error
+	)
+
+	err = rawConn.Control(func(fd uintptr) {
+		credentials, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to access connection: %w", err)
+	}
+
+	if credErr != nil {
+		return 0, fmt.Errorf("failed to get peer credentials: %w", credErr)
+	}
+
+	return int(credentials.Uid), nil
+}
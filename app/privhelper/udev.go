@@ -0,0 +1,41 @@
+package privhelper
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"git.lunr.sh/UnrealXR/unrealxr/ardriver/xreal"
+)
+
+// Default path to install the udev rules to
+const UdevRulesPath = "/etc/udev/rules.d/70-unrealxr.rules"
+
+// Generates udev rules that give the given group (and the active seat, through uaccess) access to supported XR devices and EVDI.
+func GenerateUdevRules(group string) string {
+	rules := &strings.Builder{}
+
+	rules.WriteString("# Generated by `unrealxr setup`. Re-run it instead of editing this file.\n\n")
+
+	productIDs := []uint16{}
+
+	for productID := range xreal.ProductIDs {
+		productIDs = append(productIDs, productID)
+	}
+
+	slices.Sort(productIDs)
+
+	for _, productID := range productIDs {
+		fmt.Fprintf(rules, "# %s\n", xreal.ProductIDs[productID])
+		fmt.Fprintf(rules, "SUBSYSTEM==\"hidraw\", ATTRS{idVendor}==\"%04x\", ATTRS{idProduct}==\"%04x\", MODE=\"0660\", GROUP=\"%s\", TAG+=\"uaccess\"\n", xreal.VendorID, productID, group)
+		fmt.Fprintf(rules, "SUBSYSTEM==\"usb\", ATTR{idVendor}==\"%04x\", ATTR{idProduct}==\"%04x\", MODE=\"0660\", GROUP=\"%s\", TAG+=\"uaccess\"\n\n", xreal.VendorID, productID, group)
+	}
+
+	rules.WriteString("# EVDI virtual displays\n")
+	fmt.Fprintf(rules, "SUBSYSTEM==\"drm\", KERNEL==\"card[0-9]*\", DRIVERS==\"evdi\", MODE=\"0660\", GROUP=\"%s\", TAG+=\"uaccess\"\n\n", group)
+
+	rules.WriteString("# Adding EVDI virtual displays\n")
+	fmt.Fprintf(rules, "ACTION!=\"remove\", DEVPATH==\"/devices/evdi\", RUN+=\"/bin/sh -c 'chgrp %s /sys%%p/add && chmod g+w /sys%%p/add'\"\n", group)
+
+	return rules.String()
+}
@@ -0,0 +1,89 @@
+//go:build !linux
+// +build !linux
+
+package privhelper
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Implements Operations
+type DirectOperations struct{}
+
+func NewDirectOperations() *DirectOperations {
+	return &DirectOperations{}
+}
+
+func (ops *DirectOperations) WriteEDIDOverride(card, connector string, edid []byte) error {
+	return fmt.Errorf("EDID overrides are only supported on Linux")
+}
+
+func (ops *DirectOperations) ResetEDIDOverride(card, connector string) error {
+	return fmt.Errorf("EDID overrides are only supported on Linux")
+}
+
+func (ops *DirectOperations) AddEvdiDevices(count int) error {
+	return fmt.Errorf("EVDI is only supported on Linux")
+}
+
+func (ops *DirectOperations) OpenHIDRaw(path string) (*os.File, error) {
+	return nil, fmt.Errorf("hidraw is only supported on Linux")
+}
+
+func (ops *DirectOperations) CreateVirtualPointer() (*os.File, error) {
+	return nil, fmt.Errorf("uinput is only supported on Linux")
+}
+
+func (ops *DirectOperations) Close() error {
+	return nil
+}
+
+// Implements Operations
+type Client struct{}
+
+func Dial(ctx context.Context, socketPath string) (*Client, error) {
+	return nil, fmt.Errorf("the privileged helper is only supported on Linux")
+}
+
+func (client *Client) WriteEDIDOverride(card, connector string, edid []byte) error {
+	return fmt.Errorf("the privileged helper is only supported on Linux")
+}
+
+func (client *Client) ResetEDIDOverride(card, connector string) error {
+	return fmt.Errorf("the privileged helper is only supported on Linux")
+}
+
+func (client *Client) AddEvdiDevices(count int) error {
+	return fmt.Errorf("the privileged helper is only supported on Linux")
+}
+
+func (client *Client) OpenHIDRaw(path string) (*os.File, error) {
+	return nil, fmt.Errorf("the privileged helper is only supported on Linux")
+}
+
+func (client *Client) CreateVirtualPointer() (*os.File, error) {
+	return nil, fmt.Errorf("the privileged helper is only supported on Linux")
+}
+
+func (client *Client) Close() error {
+	return nil
+}
+
+func Serve(socketPath string, clientUID int, logger func(format string, args ...any)) error {
+	return fmt.Errorf("the privileged helper is only supported on Linux")
+}
+
+func XRDeviceHIDRawNodes() []string {
+	return []string{}
+}
+
+func CheckAccess(card, connector string) []*AccessProblem {
+	return []*AccessProblem{
+		{
+			Path:        "/",
+			Description: "UnrealXR is only supported on Linux",
+		},
+	}
+}
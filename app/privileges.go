@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"time"
+
+	libconfig "git.lunr.sh/UnrealXR/unrealxr/app/config"
+	"git.lunr.sh/UnrealXR/unrealxr/app/edidtools"
+	"git.lunr.sh/UnrealXR/unrealxr/app/platformtools"
+	"git.lunr.sh/UnrealXR/unrealxr/app/privhelper"
+	"git.lunr.sh/UnrealXR/unrealxr/app/uinput"
+	"github.com/charmbracelet/log"
+)
+
+const (
+	helperExecutableName = "unrealxr-helper"
+	// Generous, as the user may need to type in their password first
+	helperStartupTimeout = 2 * time.Minute
+)
+
+// Finds the privileged helper executable. We prefer the one next to our own executable
+func findHelperExecutable() (string, error) {
+	executablePath, err := os.Executable()
+
+	if err == nil {
+		helperPath := path.Join(path.Dir(executablePath), helperExecutableName)
+
+		if _, err := os.Stat(helperPath); err == nil {
+			return helperPath, nil
+		}
+	}
+
+	helperPath, err := exec.LookPath(helperExecutableName)
+
+	if err != nil {
+		return "", fmt.Errorf("could not find '%s' next to the UnrealXR executable or in PATH", helperExecutableName)
+	}
+
+	return helperPath, nil
+}
+
+// Creates a private directory for the helper socket
+func createHelperSocketDir() (string, error) {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+
+	if runtimeDir == "" {
+		return os.MkdirTemp("", "unrealxr-")
+	}
+
+	socketDir := path.Join(runtimeDir, "unrealxr")
+
+	if err := os.MkdirAll(socketDir, 0o700); err != nil {
+		return "", err
+	}
+
+	// MkdirAll doesn't touch the mode of existing directories
+	if err := os.Chmod(socketDir, 0o700); err != nil {
+		return "", err
+	}
+
+	return socketDir, nil
+}
+
+// Gets the privilege escalation options from the config
+func escalationOptions(config *libconfig.Config) *platformtools.EscalationOptions {
+	return &platformtools.EscalationOptions{
+		Backend:              *config.Privileges.EscalationBackend,
+		EnvironmentAllowlist: config.Privileges.EnvironmentAllowlist,
+	}
+}
+
+// Starts the privileged helper and connects to it
+func startPrivilegedHelper(options *platformtools.EscalationOptions) (privhelper.Operations, error) {
+	helperPath, err := findHelperExecutable()
+
+	if err != nil {
+		return nil, err
+	}
+
+	socketDir, err := createHelperSocketDir()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create helper socket directory: %w", err)
+	}
+
+	socketPath := path.Join(socketDir, "helper-"+strconv.Itoa(os.Getpid())+".sock")
+	log.Debugf("Starting privileged helper '%s' on '%s'", helperPath, socketPath)
+
+	helperProcess, err := platformtools.StartPrivilegedHelper(options, helperPath, "--socket", socketPath, "--uid", strconv.Itoa(os.Getuid()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), helperStartupTimeout)
+	defer cancel()
+
+	// Stop waiting early if the helper dies (ie. authentication was dismissed)
+	go func() {
+		helperProcess.Wait()
+		cancel()
+	}()
+
+	client, err := privhelper.Dial(ctx, socketPath)
+
+	if err != nil {
+		helperProcess.Process.Kill()
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// Sets up the privileged operations. If every node we need is accessible (see `unrealxr setup`), they're done directly without escalating. Otherwise, the privileged helper is started
+func initializePrivilegedOperations(options *platformtools.EscalationOptions, displayMetadata *edidtools.DisplayMetadata) (privhelper.Operations, error) {
+	problems := privhelper.CheckAccess(displayMetadata.LinuxDRMCard, displayMetadata.LinuxDRMConnector)
+
+	if len(problems) == 0 {
+		log.Info("All required devices are accessible. Skipping privilege escalation")
+		return privhelper.NewDirectOperations(), nil
+	}
+
+	for _, problem := range problems {
+		log.Debugf("No access to '%s' (%s)", problem.Path, problem.Description)
+	}
+
+	log.Info("Starting privileged helper")
+	return startPrivilegedHelper(options)
+}
+
+// Creates the virtual mouse used for moving the pointer. uinput needs root, so this is done by the privileged helper unless we're root ourselves
+func openVirtualPointer(ops privhelper.Operations) (*uinput.Pointer, error) {
+	file, err := ops.CreateVirtualPointer()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return uinput.NewPointer(file), nil
+}
+
+// Opens the hidraw nodes of the XR device for the headset driver. They need root unless `unrealxr setup` was run, so this is done by the privileged helper unless they're accessible
+func openXRDeviceNodes(ops privhelper.Operations) ([]*os.File, error) {
+	files := []*os.File{}
+
+	for _, node := range privhelper.XRDeviceHIDRawNodes() {
+		file, err := ops.OpenHIDRaw(node)
+
+		if err != nil {
+			for _, file := range files {
+				file.Close()
+			}
+
+			return nil, err
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,255 @@
+package renderer
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"unsafe"
+
+	libconfig "git.lunr.sh/UnrealXR/unrealxr/app/config"
+	arcommons "git.lunr.sh/UnrealXR/unrealxr/ardriver/commons"
+
+	rl "git.lunr.sh/UnrealXR/raylib-go/raylib"
+)
+
+// Sizes of the procedural backgrounds, in world units
+const (
+	// How far below the viewer the floor is
+	floorDepth  = 3.0
+	gridSize    = 40
+	gridSpacing = 1.0
+	roomWidth   = 16.0
+	roomHeight  = 8.0
+	// Far enough to be behind everything, but closer than the far clipping plane
+	skyboxRadius = 500.0
+)
+
+// Detail of the skybox sphere
+const (
+	skyboxRings  = 32
+	skyboxSlices = 64
+)
+
+const meshBufferTexcoords = 1
+
+var backgroundLineColor = rl.NewColor(90, 90, 90, 255)
+
+// Draws what is behind the displays. Can be dimmed, also depending on the blend state of the glasses
+type background struct {
+	kind string
+	// Clear color, with the opacity applied
+	color  color.RGBA
+	skybox rl.Model
+	dim    float32
+	// Dim levels that replace dim while the glasses are in a blend state
+	blendDim map[arcommons.ARBlendState]float32
+	// Last blend state the glasses reported, or -1. Set from the driver's goroutine
+	blendState atomic.Int32
+}
+
+// Parses a "#rrggbb" color from the config
+func parseHexColor(hex string) (color.RGBA, error) {
+	value, err := strconv.ParseUint(hex[1:], 16, 32)
+
+	if err != nil || len(hex) != 7 {
+		return color.RGBA{}, fmt.Errorf("invalid color '%s'", hex)
+	}
+
+	return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 255}, nil
+}
+
+// Scales the brightness of an opaque color. On see-through glasses, darker colors let more of the real world through
+func scaleColor(original color.RGBA, factor float32) color.RGBA {
+	return color.RGBA{
+		R: uint8(float32(original.R) * factor),
+		G: uint8(float32(original.G) * factor),
+		B: uint8(float32(original.B) * factor),
+		A: 255,
+	}
+}
+
+func loadBackground(backgroundConfig libconfig.BackgroundConfig, configDir string) (*background, error) {
+	baseColor, err := parseHexColor(*backgroundConfig.Color)
+
+	if err != nil {
+		return nil, err
+	}
+
+	background := &background{
+		kind:     *backgroundConfig.Type,
+		color:    scaleColor(baseColor, *backgroundConfig.Opacity),
+		dim:      *backgroundConfig.Dim,
+		blendDim: map[arcommons.ARBlendState]float32{},
+	}
+
+	background.blendState.Store(-1)
+
+	for name, dim := range backgroundConfig.BlendDim {
+		background.blendDim[arcommons.ARBlendStateNames[name]] = dim
+	}
+
+	if background.kind == libconfig.BackgroundSkybox {
+		imagePath := *backgroundConfig.Image
+
+		if !filepath.IsAbs(imagePath) {
+			imagePath = filepath.Join(configDir, imagePath)
+		}
+
+		background.skybox, err = loadSkybox(imagePath)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return background, nil
+}
+
+// Loads an equirectangular image onto the inside of a sphere around the viewer
+func loadSkybox(imagePath string) (rl.Model, error) {
+	image := rl.LoadImage(imagePath)
+
+	if image.Width == 0 || image.Height == 0 {
+		return rl.Model{}, fmt.Errorf("failed to load skybox image '%s'", imagePath)
+	}
+
+	texture := rl.LoadTextureFromImage(image)
+	rl.UnloadImage(image)
+	rl.SetTextureFilter(texture, rl.FilterBilinear)
+
+	mesh := rl.GenMeshSphere(skyboxRadius, skyboxRings, skyboxSlices)
+	mapEquirectangular(mesh)
+
+	model := rl.LoadModelFromMesh(mesh)
+	rl.SetMaterialTexture(model.Materials, rl.MapAlbedo, texture)
+
+	return model, nil
+}
+
+// Sets the texture coordinates of a sphere mesh (with a vertex per triangle corner), so an equirectangular image is centered straight ahead, upright as seen from the inside
+func mapEquirectangular(mesh rl.Mesh) {
+	vertices := unsafe.Slice(mesh.Vertices, mesh.VertexCount*3)
+	texcoords := unsafe.Slice(mesh.Texcoords, mesh.VertexCount*2)
+
+	for triangle := range int(mesh.VertexCount) / 3 {
+		var u, v [3]float32
+		var isPole [3]bool
+
+		minU, maxU := float32(1), float32(0)
+
+		for corner := range 3 {
+			vertex := (triangle*3 + corner) * 3
+			x, y, z := float64(vertices[vertex]), float64(vertices[vertex+1]), float64(vertices[vertex+2])
+			radius := math.Sqrt(x*x + y*y + z*z)
+
+			u[corner] = float32(0.5 + math.Atan2(x, -z)/(2*math.Pi))
+			v[corner] = float32(0.5 - math.Asin(y/radius)/math.Pi)
+			isPole[corner] = x*x+z*z < 1e-6*radius*radius
+
+			if !isPole[corner] {
+				minU = min(minU, u[corner])
+				maxU = max(maxU, u[corner])
+			}
+		}
+
+		// Triangles where the image wraps around behind the viewer would otherwise stretch across all of it. Textures repeat, so going past 1 wraps around
+		if maxU-minU > 0.5 {
+			for corner := range 3 {
+				if u[corner] < 0.5 {
+					u[corner]++
+				}
+			}
+		}
+
+		// The poles have no direction of their own, so they take the one of the rest of their triangle
+		poleU, poleCount := float32(0), 0
+
+		for corner := range 3 {
+			if !isPole[corner] {
+				poleU += u[corner]
+				poleCount++
+			}
+		}
+
+		for corner := range 3 {
+			if isPole[corner] && poleCount > 0 {
+				u[corner] = poleU / float32(poleCount)
+			}
+
+			texcoords[(triangle*3+corner)*2] = u[corner]
+			texcoords[(triangle*3+corner)*2+1] = v[corner]
+		}
+	}
+
+	rl.UpdateMeshBuffer(mesh, meshBufferTexcoords, unsafe.Slice((*byte)(unsafe.Pointer(&texcoords[0])), len(texcoords)*4), 0)
+}
+
+// Records a blend state the glasses reported. Safe to call from any goroutine
+func (background *background) setBlendState(blendState arcommons.ARBlendState) {
+	background.blendState.Store(int32(blendState))
+}
+
+// Gets how bright the background is drawn, depending on the dim level and blend state
+func (background *background) brightness() float32 {
+	dim := background.dim
+
+	if blendState := background.blendState.Load(); blendState != -1 {
+		if blendDim, ok := background.blendDim[arcommons.ARBlendState(blendState)]; ok {
+			dim = blendDim
+		}
+	}
+
+	return 1 - dim
+}
+
+// Checks if the background looks the same in every direction, so moving the head doesn't change what's on screen
+func (background *background) isStatic() bool {
+	return background.kind == libconfig.BackgroundSolid
+}
+
+// Gets the color to clear the screen with before drawing
+func (background *background) clearColor() color.RGBA {
+	return scaleColor(background.color, background.brightness())
+}
+
+// Draws the background around the viewer. This happens in 3D mode, before the displays are drawn
+func (background *background) draw(viewerPosition rl.Vector3) {
+	brightness := background.brightness()
+	lineColor := scaleColor(backgroundLineColor, brightness)
+	floor := viewerPosition.Y - floorDepth
+
+	switch background.kind {
+	case libconfig.BackgroundSkybox:
+		// The sphere is seen from the inside, which is its back
+		rl.DisableBackfaceCulling()
+		rl.DrawModel(background.skybox, viewerPosition, 1, scaleColor(rl.White, brightness))
+		rl.EnableBackfaceCulling()
+
+	case libconfig.BackgroundRoom:
+		rl.DrawCubeWires(rl.Vector3{X: viewerPosition.X, Y: floor + roomHeight/2, Z: viewerPosition.Z}, roomWidth, roomHeight, roomWidth, lineColor)
+		drawFloorGrid(viewerPosition, floor, roomWidth/2, lineColor)
+
+	case libconfig.BackgroundGrid:
+		drawFloorGrid(viewerPosition, floor, gridSize/2, lineColor)
+	}
+}
+
+// Draws a grid of lines on the floor, centered below the viewer
+func drawFloorGrid(viewerPosition rl.Vector3, floor, halfSize float32, lineColor color.RGBA) {
+	for offset := -halfSize; offset <= halfSize; offset += gridSpacing {
+		rl.DrawLine3D(
+			rl.Vector3{X: viewerPosition.X + offset, Y: floor, Z: viewerPosition.Z - halfSize},
+			rl.Vector3{X: viewerPosition.X + offset, Y: floor, Z: viewerPosition.Z + halfSize},
+			lineColor,
+		)
+
+		rl.DrawLine3D(
+			rl.Vector3{X: viewerPosition.X - halfSize, Y: floor, Z: viewerPosition.Z + offset},
+			rl.Vector3{X: viewerPosition.X + halfSize, Y: floor, Z: viewerPosition.Z + offset},
+			lineColor,
+		)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build linux
+// +build linux
+
+package renderer
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Reads the charge of the first battery, in percent, and whether it's charging. Returns false if there is no battery
+func readBattery() (percent int, isCharging bool, ok bool) {
+	supplies, err := filepath.Glob("/sys/class/power_supply/*")
+
+	if err != nil {
+		return 0, false, false
+	}
+
+	for _, supply := range supplies {
+		supplyType, err := os.ReadFile(filepath.Join(supply, "type"))
+
+		if err != nil || strings.TrimSpace(string(supplyType)) != "Battery" {
+			continue
+		}
+
+		capacity, err := os.ReadFile(filepath.Join(supply, "capacity"))
+
+		if err != nil {
+			continue
+		}
+
+		percent, err := strconv.Atoi(strings.TrimSpace(string(capacity)))
+
+		if err != nil {
+			continue
+		}
+
+		status, _ := os.ReadFile(filepath.Join(supply, "status"))
+
+		return percent, strings.TrimSpace(string(status)) == "Charging", true
+	}
+
+	return 0, false, false
+}
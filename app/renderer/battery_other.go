@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package renderer
+
+// Reading the battery is only supported on Linux
+func readBattery() (percent int, isCharging bool, ok bool) {
+	return 0, false, false
+}
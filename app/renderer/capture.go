@@ -0,0 +1,395 @@
+package renderer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"git.lunr.sh/UnrealXR/unrealxr/evdi/libevdi"
+	"github.com/charmbracelet/log"
+)
+
+// Number of buffers each display captures into: one being captured into, one holding the latest frame, and one being uploaded by the renderer
+const captureBufferCount = 3
+
+// How long the capture goroutine blocks on the EVDI event FD before checking if it should stop
+const captureEventTimeout = 100 * time.Millisecond
+
+type capturedFrame struct {
+	buffer *libevdi.EvdiBuffer
+	rect   *libevdi.EvdiDisplayRect
+
+	// Regions that changed since this buffer was last captured into. Only accessed by the capture goroutine
+	stale []damageRect
+}
+
+// Captures frames of a single virtual display on its own goroutine, so slow grabs never block the render loop
+type displayCapture struct {
+	card   *EvdiDisplayMetadata
+	frames []*capturedFrame
+	width  int
+	height int
+
+	// Protects frames, width, height, latestFrame, readingFrame, pendingDamage, cursor, dpmsMode, crtcState and failure
+	lock sync.Mutex
+	// Signalled when the renderer releases a frame
+	frameReleased *sync.Cond
+	// Newest complete frame that the renderer hasn't picked up yet
+	latestFrame *capturedFrame
+	// Frame the renderer is currently uploading from
+	readingFrame *capturedFrame
+	// Regions that changed since the renderer last picked up a frame
+	pendingDamage []damageRect
+	cursor        cursorState
+	// Power state set by the compositor. 0 is on, anything else is some kind of off
+	dpmsMode  int
+	crtcState int
+	// Why the display can't be captured, if it can't. Only set by the capture goroutine
+	failure error
+	// Most recently completed frame. Only accessed by the capture goroutine
+	completeFrame *capturedFrame
+
+	updateReady bool
+	// Mode the display switched to, if it hasn't been handled yet. Only accessed by the capture goroutine
+	pendingMode *libevdi.EvdiMode
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Creates and clears the buffers frames are captured into
+func createFrames(node *libevdi.EvdiNode, width, height int) ([]*capturedFrame, error) {
+	frames := make([]*capturedFrame, 0, captureBufferCount)
+
+	for i := range captureBufferCount {
+		rect := &libevdi.EvdiDisplayRect{
+			X1: 0,
+			Y1: 0,
+			X2: width,
+			Y2: height,
+		}
+
+		buffer, err := node.CreateBuffer(width, height, libevdi.StridePixelFormatRGBA32, rect)
+
+		if err != nil {
+			for _, frame := range frames {
+				node.RemoveBuffer(frame.buffer)
+			}
+
+			return nil, fmt.Errorf("failed to create capture buffer %d: %w", i, err)
+		}
+
+		// Parts EVDI doesn't report as dirty in the first grab should be black, not garbage
+		clear(buffer.Buffer)
+
+		frames = append(frames, &capturedFrame{
+			buffer: buffer,
+			rect:   rect,
+			stale:  []damageRect{{X1: 0, Y1: 0, X2: width, Y2: height}},
+		})
+	}
+
+	return frames, nil
+}
+
+// Creates the capture buffers for a display and starts capturing
+func startCapture(card *EvdiDisplayMetadata, width, height int) (*displayCapture, error) {
+	frames, err := createFrames(card.EvdiNode, width, height)
+
+	if err != nil {
+		return nil, err
+	}
+
+	capture := &displayCapture{
+		card:          card,
+		frames:        frames,
+		width:         width,
+		height:        height,
+		pendingDamage: []damageRect{{X1: 0, Y1: 0, X2: width, Y2: height}},
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	capture.frameReleased = sync.NewCond(&capture.lock)
+
+	card.EventContext.UpdateReadyHandler = func(bufferToBeUpdated int) {
+		capture.updateReady = true
+	}
+
+	card.EventContext.ModeChangeHandler = func(mode *libevdi.EvdiMode) {
+		capture.pendingMode = mode
+	}
+
+	card.EventContext.DPMSHandler = func(dpmsMode int) {
+		capture.lock.Lock()
+		defer capture.lock.Unlock()
+
+		log.Debugf("Virtual display DPMS mode changed to %d", dpmsMode)
+		capture.dpmsMode = dpmsMode
+	}
+
+	card.EventContext.CRTCStateHandler = func(state int) {
+		capture.lock.Lock()
+		defer capture.lock.Unlock()
+
+		log.Debugf("Virtual display CRTC state changed to %d", state)
+		capture.crtcState = state
+	}
+
+	capture.enableCursorEvents()
+
+	card.capture = capture
+	go capture.run()
+
+	return capture, nil
+}
+
+// Replaces the capture buffers with ones of a new size. Waits for the renderer to be done with the frame it's uploading first
+func (capture *displayCapture) reallocate(width, height int) error {
+	capture.lock.Lock()
+
+	for capture.readingFrame != nil {
+		capture.frameReleased.Wait()
+	}
+
+	oldFrames := capture.frames
+
+	capture.frames = nil
+	capture.latestFrame = nil
+	capture.completeFrame = nil
+	capture.lock.Unlock()
+
+	for _, frame := range oldFrames {
+		capture.card.EvdiNode.RemoveBuffer(frame.buffer)
+	}
+
+	frames, err := createFrames(capture.card.EvdiNode, width, height)
+
+	if err != nil {
+		return err
+	}
+
+	capture.lock.Lock()
+	defer capture.lock.Unlock()
+
+	capture.frames = frames
+	capture.width = width
+	capture.height = height
+	capture.pendingDamage = []damageRect{{X1: 0, Y1: 0, X2: width, Y2: height}}
+
+	return nil
+}
+
+// Picks a frame that is neither waiting for the renderer nor being uploaded by it
+func (capture *displayCapture) nextFreeFrame() (*capturedFrame, error) {
+	capture.lock.Lock()
+	defer capture.lock.Unlock()
+
+	for _, frame := range capture.frames {
+		if frame != capture.latestFrame && frame != capture.readingFrame {
+			return frame, nil
+		}
+	}
+
+	// Shouldn't happen with at least 3 buffers
+	return nil, fmt.Errorf("no free capture buffer out of %d", len(capture.frames))
+}
+
+// Gets why the display can't be captured, or nil if it's fine
+func (capture *displayCapture) failed() error {
+	capture.lock.Lock()
+	defer capture.lock.Unlock()
+
+	return capture.failure
+}
+
+func (capture *displayCapture) setFailure(failure error) {
+	capture.lock.Lock()
+	defer capture.lock.Unlock()
+
+	capture.failure = failure
+}
+
+// Checks if the compositor has the display turned on
+func (capture *displayCapture) isDisplayOn() bool {
+	capture.lock.Lock()
+	defer capture.lock.Unlock()
+
+	return capture.dpmsMode == 0 && capture.crtcState == 0
+}
+
+// Handles EVDI events until done returns true. Returns false if the capture is stopped in the meantime
+func (capture *displayCapture) handleEventsUntil(done func() bool) bool {
+	node := capture.card.EvdiNode
+
+	for !done() {
+		select {
+		case <-capture.stop:
+			return false
+		default:
+		}
+
+		ready, err := node.WaitUntilEventsAreReadyToHandle(captureEventTimeout)
+
+		if err != nil {
+			log.Errorf("Failed to wait for display events: %s", err.Error())
+			continue
+		}
+
+		if !ready {
+			continue
+		}
+
+		if err := node.HandleEvents(capture.card.EventContext); err != nil {
+			log.Errorf("Failed to handle display events: %s", err.Error())
+		}
+	}
+
+	return true
+}
+
+func (capture *displayCapture) run() {
+	defer close(capture.done)
+	node := capture.card.EvdiNode
+
+	for {
+		select {
+		case <-capture.stop:
+			return
+		default:
+		}
+
+		if mode := capture.pendingMode; mode != nil {
+			capture.pendingMode = nil
+
+			// After a failed reallocation, even the old mode needs new buffers
+			if mode.Width == capture.width && mode.Height == capture.height && capture.failure == nil {
+				continue
+			}
+
+			log.Infof("Virtual display mode changed to %dx%d@%d", mode.Width, mode.Height, mode.RefreshRate)
+
+			if err := capture.reallocate(mode.Width, mode.Height); err != nil {
+				log.Errorf("Failed to reallocate capture buffers after mode change, retrying on the next one: %s", err.Error())
+				capture.setFailure(fmt.Errorf("failed to reallocate capture buffers: %w", err))
+				continue
+			}
+
+			capture.setFailure(nil)
+			continue
+		}
+
+		// There are no buffers to capture into, so only wait for the next mode change to try again
+		if capture.failure != nil {
+			if !capture.handleEventsUntil(func() bool { return capture.pendingMode != nil }) {
+				return
+			}
+
+			continue
+		}
+
+		// Nothing to capture while the display is off. We only keep handling events to find out when it turns back on
+		if !capture.isDisplayOn() {
+			if !capture.handleEventsUntil(func() bool { return capture.isDisplayOn() || capture.pendingMode != nil }) {
+				return
+			}
+
+			continue
+		}
+
+		frame, err := capture.nextFreeFrame()
+
+		if err != nil {
+			log.Errorf("Skipping frame: %s", err.Error())
+
+			// Give the renderer some time to release a frame, while still noticing mode changes
+			deadline := time.Now().Add(captureEventTimeout)
+
+			if !capture.handleEventsUntil(func() bool { return capture.pendingMode != nil || time.Now().After(deadline) }) {
+				return
+			}
+
+			continue
+		}
+
+		// EVDI only copies the regions that changed since the last grab, so whatever changed since this buffer was last used has to come from the last complete frame
+		if capture.completeFrame != nil && capture.completeFrame != frame {
+			copyDamage(frame.buffer.Buffer, capture.completeFrame.buffer.Buffer, capture.width, frame.stale)
+		}
+
+		frame.stale = nil
+
+		capture.updateReady = node.RequestUpdate(frame.buffer)
+
+		if !capture.handleEventsUntil(func() bool { return capture.updateReady || capture.pendingMode != nil }) {
+			return
+		}
+
+		// The mode change is handled at the start of the next iteration
+		if capture.pendingMode != nil {
+			continue
+		}
+
+		dirtyRects, err := node.GrabDirtyRects(frame.rect)
+
+		if err != nil {
+			log.Errorf("Failed to grab pixels: %s", err.Error())
+			continue
+		}
+
+		damage := damageFromDirtyRects(dirtyRects, capture.width, capture.height)
+
+		for _, otherFrame := range capture.frames {
+			if otherFrame != frame {
+				otherFrame.stale = mergeDamage(append(otherFrame.stale, damage...))
+			}
+		}
+
+		capture.completeFrame = frame
+
+		capture.lock.Lock()
+		capture.latestFrame = frame
+		capture.pendingDamage = mergeDamage(append(capture.pendingDamage, damage...))
+		capture.lock.Unlock()
+	}
+}
+
+// Takes the newest frame that hasn't been picked up yet, or nil if there is none, along with the regions that changed since the last frame that was picked up.
+// The frame must be given back with releaseFrame
+func (capture *displayCapture) acquireLatestFrame() (*capturedFrame, []damageRect) {
+	capture.lock.Lock()
+	defer capture.lock.Unlock()
+
+	if capture.latestFrame == nil {
+		return nil, nil
+	}
+
+	damage := capture.pendingDamage
+
+	capture.readingFrame = capture.latestFrame
+	capture.latestFrame = nil
+	capture.pendingDamage = nil
+
+	return capture.readingFrame, damage
+}
+
+// Gives a frame from acquireLatestFrame back to the capture goroutine
+func (capture *displayCapture) releaseFrame(frame *capturedFrame) {
+	capture.lock.Lock()
+	defer capture.lock.Unlock()
+
+	if capture.readingFrame == frame {
+		capture.readingFrame = nil
+		capture.frameReleased.Broadcast()
+	}
+}
+
+// Stops capturing and waits for the capture goroutine to exit
+func (capture *displayCapture) stopCapture() {
+	capture.stopOnce.Do(func() {
+		close(capture.stop)
+	})
+
+	<-capture.done
+}
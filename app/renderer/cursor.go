@@ -0,0 +1,238 @@
+package renderer
+
+import (
+	"fmt"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/headtracking"
+	"git.lunr.sh/UnrealXR/unrealxr/evdi/libevdi"
+	"github.com/charmbracelet/log"
+
+	rl "git.lunr.sh/UnrealXR/raylib-go/raylib"
+)
+
+// DRM fourcc pixel formats that cursors are sent in
+const (
+	drmFormatARGB8888 = 0x34325241 // 'AR24', stored as B, G, R, A
+	drmFormatABGR8888 = 0x34324241 // 'AB24', stored as R, G, B, A
+)
+
+// Cursor image, as tightly packed RGBA pixels
+type cursorImage struct {
+	pixels []byte
+	width  int
+	height int
+	// Point of the image that the pointer is at
+	hotX, hotY int
+}
+
+// Last known cursor of a display, as reported by EVDI cursor events
+type cursorState struct {
+	image   *cursorImage
+	enabled bool
+	// Position of the top left corner of the cursor image, in display pixels
+	x, y int
+	// Increases every time the image changes
+	serial int
+}
+
+// Converts a cursor image from EVDI to packed RGBA
+func cursorImageFromEvdi(cursor *libevdi.EvdiCursorSet) (*cursorImage, error) {
+	width, height, stride := int(cursor.Width), int(cursor.Height), int(cursor.Stride)
+
+	if width == 0 || height == 0 || stride < width*4 || len(cursor.Buffer) < stride*(height-1)+width*4 {
+		return nil, fmt.Errorf("cursor buffer is too small for a %dx%d cursor", width, height)
+	}
+
+	if cursor.PixelFormat != drmFormatARGB8888 && cursor.PixelFormat != drmFormatABGR8888 {
+		return nil, fmt.Errorf("unsupported cursor pixel format 0x%08x", cursor.PixelFormat)
+	}
+
+	pixels := make([]byte, 0, width*height*4)
+
+	for y := range height {
+		row := cursor.Buffer[y*stride : y*stride+width*4]
+
+		for x := 0; x < len(row); x += 4 {
+			if cursor.PixelFormat == drmFormatARGB8888 {
+				pixels = append(pixels, row[x+2], row[x+1], row[x], row[x+3])
+			} else {
+				pixels = append(pixels, row[x], row[x+1], row[x+2], row[x+3])
+			}
+		}
+	}
+
+	return &cursorImage{
+		pixels: pixels,
+		width:  width,
+		height: height,
+		hotX:   int(cursor.HotX),
+		hotY:   int(cursor.HotY),
+	}, nil
+}
+
+// Gets where the pointer is on the display, in display pixels
+func (cursor cursorState) pointerPosition() (int, int) {
+	if cursor.image == nil {
+		return cursor.x, cursor.y
+	}
+
+	return cursor.x + cursor.image.hotX, cursor.y + cursor.image.hotY
+}
+
+// Registers the cursor handlers of a display and turns on cursor events, so the cursor isn't drawn into captured frames anymore
+func (capture *displayCapture) enableCursorEvents() {
+	capture.card.EventContext.CursorSetHandler = func(cursor *libevdi.EvdiCursorSet) {
+		capture.lock.Lock()
+		defer capture.lock.Unlock()
+
+		capture.cursor.enabled = cursor.Enabled != 0
+
+		if !capture.cursor.enabled {
+			return
+		}
+
+		image, err := cursorImageFromEvdi(cursor)
+
+		if err != nil {
+			log.Warnf("Ignoring cursor image: %s", err.Error())
+			capture.cursor.enabled = false
+			return
+		}
+
+		capture.cursor.image = image
+		capture.cursor.serial++
+	}
+
+	capture.card.EventContext.CursorMoveHandler = func(x, y int32) {
+		capture.lock.Lock()
+		defer capture.lock.Unlock()
+
+		capture.cursor.x = int(x)
+		capture.cursor.y = int(y)
+	}
+
+	capture.card.EvdiNode.CursorEventSwitch(true)
+}
+
+// Gets the current cursor of the display
+func (capture *displayCapture) currentCursor() cursorState {
+	capture.lock.Lock()
+	defer capture.lock.Unlock()
+
+	return capture.cursor
+}
+
+// Cursor drawn on top of a display's plane. The cursor image is its own texture, so moving the cursor never needs a new frame
+type cursorOverlay struct {
+	model   rl.Model
+	texture rl.Texture2D
+	loaded  bool
+	width   int
+	height  int
+
+	// What the model was created for
+	serial        int
+	displayWidth  int
+	displayHeight int
+}
+
+// Updates the cursor texture if the cursor image changed, and recreates the model if its size or the display's resolution changed
+func (overlay *cursorOverlay) update(cursor cursorState, displayWidth, displayHeight int, verticalSize float32) {
+	if cursor.image == nil {
+		return
+	}
+
+	if overlay.loaded && overlay.serial == cursor.serial && overlay.displayWidth == displayWidth && overlay.displayHeight == displayHeight {
+		return
+	}
+
+	isSameSize := overlay.width == cursor.image.width && overlay.height == cursor.image.height && overlay.displayWidth == displayWidth && overlay.displayHeight == displayHeight
+
+	// Only the pixels changed, so the model can stay as it is
+	if overlay.loaded && isSameSize && len(cursor.image.pixels) != 0 {
+		rl.UpdateTexture(overlay.texture, bytesToPixels(cursor.image.pixels))
+		overlay.serial = cursor.serial
+
+		return
+	}
+
+	if overlay.loaded {
+		// UnloadModel doesn't unload material textures
+		rl.UnloadTexture(overlay.texture)
+		rl.UnloadModel(overlay.model)
+	}
+
+	horizontalSize := findOptimalHorizontalRes(float32(displayHeight), float32(displayWidth), verticalSize)
+
+	image := rl.NewImage(cursor.image.pixels, int32(cursor.image.width), int32(cursor.image.height), 1, rl.UncompressedR8g8b8a8)
+	overlay.texture = rl.LoadTextureFromImage(image)
+
+	overlay.model = rl.LoadModelFromMesh(rl.GenMeshPlane(
+		horizontalSize*float32(cursor.image.width)/float32(displayWidth),
+		verticalSize*float32(cursor.image.height)/float32(displayHeight),
+		1, 1,
+	))
+
+	rl.SetMaterialTexture(overlay.model.Materials, rl.MapAlbedo, overlay.texture)
+
+	overlay.loaded = true
+	overlay.width = cursor.image.width
+	overlay.height = cursor.image.height
+	overlay.serial = cursor.serial
+	overlay.displayWidth = displayWidth
+	overlay.displayHeight = displayHeight
+}
+
+// Draws the cursor on the display plane with the given transform, position and curve radius (0 if the display is flat)
+func (overlay *cursorOverlay) draw(cursor cursorState, displayRotation headtracking.Quaternion, displayScale float32, worldPos rl.Vector3, verticalSize, curveRadius float32) {
+	if !overlay.loaded || !cursor.enabled {
+		return
+	}
+
+	horizontalSize := findOptimalHorizontalRes(float32(overlay.displayHeight), float32(overlay.displayWidth), verticalSize)
+
+	// Position of the cursor's center on the plane, in the plane's own coordinates (before it's rotated upright), matching how the display texture is mapped onto it
+	centerX := float32(cursor.x) + float32(overlay.width)/2
+	centerY := float32(cursor.y) + float32(overlay.height)/2
+
+	localX := (centerX/float32(overlay.displayWidth) - 0.5) * horizontalSize
+	localZ := (centerY/float32(overlay.displayHeight) - 0.5) * verticalSize
+
+	position := headtracking.Vector3{X: localX, Y: 0, Z: localZ}
+	rotation := displayRotation
+
+	// On a curved display, the cursor follows the curve and turns with its surface
+	if curveRadius > 0 {
+		bentX, depth, angle := bendOntoCurve(localX, curveRadius)
+
+		position = headtracking.Vector3{X: bentX, Y: depth, Z: localZ}
+		rotation = displayRotation.Multiply(headtracking.QuaternionFromAxisAngle(headtracking.Vector3{X: 0, Y: 0, Z: 1}, angle))
+	}
+
+	offset := displayRotation.Rotate(position)
+
+	worldPos = rl.Vector3Add(worldPos, rl.Vector3Scale(toRaylibVector(offset), displayScale))
+	overlay.model.Transform = matrixFromRotation(rotation, displayScale)
+
+	// The cursor lies exactly on the plane, so it'd fight with it over depth
+	rl.DisableDepthTest()
+
+	rl.DrawModelEx(
+		overlay.model,
+		worldPos,
+		rl.Vector3{
+			X: 0,
+			Y: 0,
+			Z: 0,
+		},
+		0,
+		rl.Vector3{
+			X: 1,
+			Y: 1,
+			Z: 1,
+		},
+		rl.White,
+	)
+
+	rl.EnableDepthTest()
+}
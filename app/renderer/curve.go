@@ -0,0 +1,55 @@
+package renderer
+
+import (
+	"math"
+	"unsafe"
+
+	rl "git.lunr.sh/UnrealXR/raylib-go/raylib"
+)
+
+// Number of vertical strips a curved display is made of
+const curveSegments = 64
+
+// Indices of mesh buffers, as raylib uploads them
+const (
+	meshBufferPositions = 0
+	meshBufferNormals   = 2
+)
+
+// Bends a horizontal position on a flat plane onto a cylinder of the given radius. Returns the new position, how far it moved towards the viewer, and the angle of the surface there.
+// The arc length is kept, so textures are mapped onto the curve without stretching
+func bendOntoCurve(x, radius float32) (bentX, depth, angle float32) {
+	angle = x / radius
+	sin, cos := math.Sincos(float64(angle))
+
+	return radius * float32(sin), radius * (1 - float32(cos)), angle
+}
+
+// Creates the mesh of a display. With a radius above 0, the display is curved around the viewer like an ultrawide monitor
+func genDisplayMesh(horizontalSize, verticalSize, curveRadius float32) rl.Mesh {
+	if curveRadius <= 0 {
+		return rl.GenMeshPlane(horizontalSize, verticalSize, 1, 1)
+	}
+
+	mesh := rl.GenMeshPlane(horizontalSize, verticalSize, curveSegments, 1)
+
+	vertices := unsafe.Slice(mesh.Vertices, mesh.VertexCount*3)
+	normals := unsafe.Slice(mesh.Normals, mesh.VertexCount*3)
+
+	for vertex := range int(mesh.VertexCount) {
+		x, depth, angle := bendOntoCurve(vertices[vertex*3], curveRadius)
+		sin, cos := math.Sincos(float64(angle))
+
+		// The plane faces the viewer with its +Y side once the display is stood upright, so the edges bend towards +Y
+		vertices[vertex*3] = x
+		vertices[vertex*3+1] = depth
+
+		normals[vertex*3] = -float32(sin)
+		normals[vertex*3+1] = float32(cos)
+	}
+
+	rl.UpdateMeshBuffer(mesh, meshBufferPositions, unsafe.Slice((*byte)(unsafe.Pointer(&vertices[0])), len(vertices)*4), 0)
+	rl.UpdateMeshBuffer(mesh, meshBufferNormals, unsafe.Slice((*byte)(unsafe.Pointer(&normals[0])), len(normals)*4), 0)
+
+	return mesh
+}
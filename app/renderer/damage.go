@@ -0,0 +1,172 @@
+package renderer
+
+import (
+	"time"
+
+	"git.lunr.sh/UnrealXR/unrealxr/evdi/libevdi"
+	"github.com/charmbracelet/log"
+)
+
+// Rough cost of a texture upload call, expressed in pixels. Two damaged regions get merged if uploading the area between them is cheaper than another upload
+const uploadOverheadPixels = 64 * 64
+
+// Most regions we keep track of at once. Beyond this, the cheapest merges are done regardless of cost
+const maxDamageRects = libevdi.MaxDirtyRects
+
+// How often upload and render statistics are logged
+const uploadStatsInterval = 10 * time.Second
+
+// Region of a frame that changed. X2 and Y2 are exclusive
+type damageRect struct {
+	X1, Y1, X2, Y2 int
+}
+
+func (rect damageRect) area() int {
+	return (rect.X2 - rect.X1) * (rect.Y2 - rect.Y1)
+}
+
+func (rect damageRect) union(other damageRect) damageRect {
+	return damageRect{
+		X1: min(rect.X1, other.X1),
+		Y1: min(rect.Y1, other.Y1),
+		X2: max(rect.X2, other.X2),
+		Y2: max(rect.Y2, other.Y2),
+	}
+}
+
+// Converts the dirty rects EVDI reported into damage, clamped to the frame size
+func damageFromDirtyRects(dirtyRects []libevdi.EvdiDisplayRect, width, height int) []damageRect {
+	damage := make([]damageRect, 0, len(dirtyRects))
+
+	for _, dirtyRect := range dirtyRects {
+		rect := damageRect{
+			X1: max(dirtyRect.X1, 0),
+			Y1: max(dirtyRect.Y1, 0),
+			X2: min(dirtyRect.X2, width),
+			Y2: min(dirtyRect.Y2, height),
+		}
+
+		if rect.X2 > rect.X1 && rect.Y2 > rect.Y1 {
+			damage = append(damage, rect)
+		}
+	}
+
+	return damage
+}
+
+// Merges damaged regions whenever uploading them together is cheaper than uploading them separately, and until at most maxDamageRects are left
+func mergeDamage(damage []damageRect) []damageRect {
+	merged := append([]damageRect{}, damage...)
+
+	for len(merged) > 1 {
+		bestFirst, bestSecond := -1, -1
+		bestCost := 0
+
+		for first := range merged {
+			for second := first + 1; second < len(merged); second++ {
+				// Extra pixels we'd upload by merging, minus the upload call we'd save
+				cost := merged[first].union(merged[second]).area() - merged[first].area() - merged[second].area() - uploadOverheadPixels
+
+				if bestFirst == -1 || cost < bestCost {
+					bestFirst, bestSecond, bestCost = first, second, cost
+				}
+			}
+		}
+
+		if bestCost > 0 && len(merged) <= maxDamageRects {
+			break
+		}
+
+		merged[bestFirst] = merged[bestFirst].union(merged[bestSecond])
+		merged = append(merged[:bestSecond], merged[bestSecond+1:]...)
+	}
+
+	return merged
+}
+
+// Copies the damaged regions of one RGBA frame into another of the same size
+func copyDamage(destination, source []byte, width int, damage []damageRect) {
+	stride := width * libevdi.StridePixelFormatRGBA32
+
+	for _, rect := range damage {
+		for y := rect.Y1; y < rect.Y2; y++ {
+			start := y*stride + rect.X1*libevdi.StridePixelFormatRGBA32
+			end := y*stride + rect.X2*libevdi.StridePixelFormatRGBA32
+
+			copy(destination[start:end], source[start:end])
+		}
+	}
+}
+
+// Copies a damaged region of an RGBA frame into a tightly packed buffer, as texture uploads of a region expect
+func packDamage(packed, source []byte, width int, rect damageRect) []byte {
+	stride := width * libevdi.StridePixelFormatRGBA32
+	packed = packed[:0]
+
+	for y := rect.Y1; y < rect.Y2; y++ {
+		start := y*stride + rect.X1*libevdi.StridePixelFormatRGBA32
+		end := y*stride + rect.X2*libevdi.StridePixelFormatRGBA32
+
+		packed = append(packed, source[start:end]...)
+	}
+
+	return packed
+}
+
+// Keeps track of how much texture data a display uploads, compared to uploading full frames
+type uploadStats struct {
+	displayNumber int
+
+	uploads        int
+	frames         int
+	uploadedBytes  int
+	fullFrameBytes int
+	since          time.Time
+}
+
+func newUploadStats(displayNumber int) *uploadStats {
+	return &uploadStats{
+		displayNumber: displayNumber,
+		since:         time.Now(),
+	}
+}
+
+// Records a frame of the given size that was uploaded as the given damaged regions
+func (stats *uploadStats) recordFrame(damage []damageRect, width, height int) {
+	stats.frames++
+	stats.uploads += len(damage)
+	stats.fullFrameBytes += width * height * libevdi.StridePixelFormatRGBA32
+
+	for _, rect := range damage {
+		stats.uploadedBytes += rect.area() * libevdi.StridePixelFormatRGBA32
+	}
+
+	elapsed := time.Since(stats.since)
+
+	if elapsed < uploadStatsInterval {
+		return
+	}
+
+	fullBytes := stats.fullFrameBytes
+	savedPercent := float64(0)
+
+	if fullBytes != 0 {
+		savedPercent = 100 * (1 - float64(stats.uploadedBytes)/float64(fullBytes))
+	}
+
+	log.Debugf(
+		"display #%d: uploaded %.01f MiB/s instead of %.01f MiB/s (%.01f%% saved), %.01f frames/s in %.01f uploads/s",
+		stats.displayNumber,
+		float64(stats.uploadedBytes)/elapsed.Seconds()/(1024*1024),
+		float64(fullBytes)/elapsed.Seconds()/(1024*1024),
+		savedPercent,
+		float64(stats.frames)/elapsed.Seconds(),
+		float64(stats.uploads)/elapsed.Seconds(),
+	)
+
+	stats.uploads = 0
+	stats.frames = 0
+	stats.uploadedBytes = 0
+	stats.fullFrameBytes = 0
+	stats.since = time.Now()
+}
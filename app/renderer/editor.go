@@ -0,0 +1,270 @@
+package renderer
+
+import (
+	"math"
+	"time"
+
+	libconfig "git.lunr.sh/UnrealXR/unrealxr/app/config"
+	"git.lunr.sh/UnrealXR/unrealxr/app/headtracking"
+	"git.lunr.sh/UnrealXR/unrealxr/app/layout"
+
+	rl "git.lunr.sh/UnrealXR/raylib-go/raylib"
+)
+
+// How quickly the layout editor changes displays while a key is held, per second
+const (
+	editorMoveSpeed  = 1.0  // in world units
+	editorTurnSpeed  = 30.0 // in degrees
+	editorScaleSpeed = 0.5  // relative to the current size
+)
+
+// Displays can't be shrunk or pushed in closer than this in the layout editor
+const (
+	editorMinScale    = 0.1
+	editorMinDistance = 0.5
+)
+
+var (
+	editorTargetColor  = rl.Yellow
+	editorGrabbedColor = rl.Green
+	editorGuideColor   = rl.SkyBlue
+)
+
+// Lets displays be moved, rotated and resized from within the headset. The display under the gaze is edited, unless one is grabbed, which then also moves along with the head
+type layoutEditor struct {
+	isActive bool
+	// Poses the layout generated, which displays go back to when they're reset
+	generatedPoses []layout.Pose
+	// In radians
+	snapAngle float32
+	// Display being edited, or -1 if none is looked at
+	target    int
+	isGrabbed bool
+	// Head orientation in the previous frame, while a display is grabbed
+	lastOrientation headtracking.Quaternion
+	// Pose of the edited display before snapping, so it can still be moved away from whatever it snapped to
+	unsnappedPose layout.Pose
+	guides        []layout.Guide
+	// Displays that were changed since the editor was opened
+	edited map[int]bool
+}
+
+func newLayoutEditor(generatedPoses []layout.Pose, snapAngleDegrees float32) *layoutEditor {
+	return &layoutEditor{
+		generatedPoses: generatedPoses,
+		snapAngle:      snapAngleDegrees * math.Pi / 180,
+		target:         -1,
+	}
+}
+
+func (editor *layoutEditor) open() {
+	editor.isActive = true
+	editor.target = -1
+	editor.isGrabbed = false
+	editor.guides = nil
+	editor.edited = map[int]bool{}
+}
+
+func (editor *layoutEditor) close() {
+	editor.isActive = false
+	editor.target = -1
+	editor.isGrabbed = false
+	editor.guides = nil
+}
+
+// Gets -1, 0 or 1 depending on which of two opposing keys are held down
+func keyAxis(positive, negative int32) float32 {
+	axis := float32(0)
+
+	if rl.IsKeyDown(positive) {
+		axis++
+	}
+
+	if rl.IsKeyDown(negative) {
+		axis--
+	}
+
+	return axis
+}
+
+// Applies the keys held down to a pose. Returns whether any of them changed it
+func editPoseWithKeyboard(pose *layout.Pose, elapsed time.Duration) bool {
+	seconds := float32(elapsed.Seconds())
+
+	horizontal := keyAxis(rl.KeyRight, rl.KeyLeft)
+	vertical := keyAxis(rl.KeyUp, rl.KeyDown)
+	depth := keyAxis(rl.KeyPageUp, rl.KeyPageDown)
+	size := keyAxis(rl.KeyEqual, rl.KeyMinus) + keyAxis(rl.KeyKpAdd, rl.KeyKpSubtract)
+
+	if horizontal == 0 && vertical == 0 && depth == 0 && size == 0 {
+		return false
+	}
+
+	if rl.IsKeyDown(rl.KeyLeftShift) || rl.IsKeyDown(rl.KeyRightShift) {
+		// Same directions as rotations in the config: right turns the right edge away, up tilts the top edge towards the viewer
+		turn := editorTurnSpeed * math.Pi / 180 * seconds
+		pose.Rotation = pose.Rotation.Multiply(headtracking.QuaternionFromAngles(horizontal*turn, vertical*turn, 0)).Normalize()
+	} else {
+		// Moves along the display itself, so it goes where it looks like it should
+		move := editorMoveSpeed * seconds
+		right := pose.Rotation.Rotate(headtracking.Vector3{X: 1, Y: 0, Z: 0})
+		up := pose.Rotation.Rotate(headtracking.Up)
+
+		pose.Position = pose.Position.Add(right.Scale(horizontal * move)).Add(up.Scale(vertical * move))
+	}
+
+	if distance := pose.Position.Length(); depth != 0 && distance > 0 {
+		newDistance := max(editorMinDistance, distance+depth*editorMoveSpeed*seconds)
+		pose.Position = pose.Position.Scale(newDistance / distance)
+	}
+
+	pose.Scale = max(editorMinScale, pose.Scale*(1+size*editorScaleSpeed*seconds))
+
+	return true
+}
+
+// Edits the display under the gaze (or the grabbed one) with the keyboard and head motion. The gaze starts at the camera and points where the camera looks
+func (editor *layoutEditor) update(rects []*TextureModelPair, gazeOrigin, gazeDirection rl.Vector3, orientation headtracking.Quaternion, elapsed time.Duration, verticalSize float32) {
+	if !editor.isActive {
+		return
+	}
+
+	if !editor.isGrabbed {
+		if target := displayUnderGaze(rects, gazeOrigin, gazeDirection, verticalSize); target != editor.target {
+			editor.target = target
+			editor.guides = nil
+
+			if target != -1 {
+				editor.unsnappedPose = rects[target].Pose
+			}
+		}
+	}
+
+	if editor.target == -1 {
+		return
+	}
+
+	rect := rects[editor.target]
+
+	if rl.IsKeyPressed(rl.KeySpace) {
+		editor.isGrabbed = !editor.isGrabbed
+		editor.lastOrientation = orientation
+
+		// Whatever the display snapped to is where it stays
+		editor.unsnappedPose = rect.Pose
+	}
+
+	if rl.IsKeyPressed(rl.KeyBackspace) {
+		rect.Pose = editor.generatedPoses[editor.target]
+		editor.unsnappedPose = rect.Pose
+		editor.guides = nil
+		editor.edited[editor.target] = true
+
+		return
+	}
+
+	pose := editor.unsnappedPose
+	isChanged := editPoseWithKeyboard(&pose, elapsed)
+
+	// Head-locked displays already move along with the head, so grabbing them can't move them any further
+	if editor.isGrabbed && rect.Anchor.Mode() != headtracking.AnchorHead {
+		motion := orientation.WithoutRoll().Multiply(editor.lastOrientation.WithoutRoll().Conjugate())
+		editor.lastOrientation = orientation
+
+		// Head motion happens in the world, but poses are in the layout, which the anchor may have rotated
+		motion = rect.AnchorRotation.Conjugate().Multiply(motion).Multiply(rect.AnchorRotation)
+
+		if headtracking.AngleBetween(motion, headtracking.IdentityQuaternion) > 0 {
+			pose.Position = motion.Rotate(pose.Position)
+			pose.Rotation = motion.Multiply(pose.Rotation).Normalize()
+			isChanged = true
+		}
+	}
+
+	if !isChanged {
+		return
+	}
+
+	others := make([]layout.Pose, 0, len(rects)-1)
+
+	for i, other := range rects {
+		if i != editor.target {
+			others = append(others, other.Pose)
+		}
+	}
+
+	editor.unsnappedPose = pose
+	rect.Pose, editor.guides = layout.Snap(pose, others, editor.snapAngle)
+	editor.edited[editor.target] = true
+}
+
+// Draws an outline around the edited display, and the guides of what it snapped to. This happens in 3D mode, after the displays are drawn
+func (editor *layoutEditor) draw(rects []*TextureModelPair, viewerPosition rl.Vector3, verticalSize float32) {
+	if !editor.isActive || editor.target == -1 {
+		return
+	}
+
+	rect := rects[editor.target]
+	color := editorTargetColor
+
+	if editor.isGrabbed {
+		color = editorGrabbedColor
+	}
+
+	drawDisplayOutline(rect, verticalSize, 1, color)
+
+	for _, guide := range editor.guides {
+		rl.DrawLine3D(
+			rl.Vector3Add(viewerPosition, toRaylibVector(rect.AnchorRotation.Rotate(guide.From))),
+			rl.Vector3Add(viewerPosition, toRaylibVector(rect.AnchorRotation.Rotate(guide.To))),
+			editorGuideColor,
+		)
+	}
+}
+
+// Rounds a value to a number of decimals, so saved poses stay readable
+func roundTo(value float32, decimals int) float32 {
+	factor := math.Pow(10, float64(decimals))
+	return float32(math.Round(float64(value)*factor) / factor)
+}
+
+// Converts a pose into how it's written in the config
+func displayPoseConfigFromPose(pose layout.Pose) *libconfig.DisplayPoseConfig {
+	scale := roundTo(pose.Scale, 2)
+
+	return &libconfig.DisplayPoseConfig{
+		Position: []float32{roundTo(pose.Position.X, 3), roundTo(pose.Position.Y, 3), roundTo(pose.Position.Z, 3)},
+		Rotation: []float32{
+			roundTo(pose.Rotation.Yaw()*180/math.Pi, 1),
+			roundTo(pose.Rotation.Pitch()*180/math.Pi, 1),
+			roundTo(pose.Rotation.Roll()*180/math.Pi, 1),
+		},
+		Scale: &scale,
+	}
+}
+
+// Saves the poses of the displays that were edited into the active profile, both in the loaded config and in config.yml. Displays that were reset lose their poses
+func (editor *layoutEditor) save(config *libconfig.Config, configDir string, rects []*TextureModelPair) error {
+	if len(editor.edited) == 0 {
+		return nil
+	}
+
+	profile := libconfig.ActiveProfile(config)
+
+	if profile.Layout.Displays == nil {
+		profile.Layout.Displays = map[int]*libconfig.DisplayPoseConfig{}
+	}
+
+	for display := range editor.edited {
+		if rects[display].Pose == editor.generatedPoses[display] {
+			delete(profile.Layout.Displays, display)
+			continue
+		}
+
+		profile.Layout.Displays[display] = displayPoseConfigFromPose(rects[display].Pose)
+	}
+
+	editor.edited = map[int]bool{}
+
+	return libconfig.WriteDisplayPoses(configDir, *config.Profile, profile.Layout.Displays)
+}
@@ -0,0 +1,125 @@
+package renderer
+
+import (
+	"image/color"
+	"math"
+	"time"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/headtracking"
+
+	rl "git.lunr.sh/UnrealXR/raylib-go/raylib"
+)
+
+// Subtle, so it doesn't distract from what's on the display
+var focusHighlightColor = color.RGBA{R: 255, G: 255, B: 255, A: 64}
+
+// How much larger the focus highlight is than the display, so it doesn't cover its edges
+const focusHighlightMargin = 1.01
+
+// Finds the display that a ray from the viewer hits first. Returns -1 if it misses all of them
+func displayUnderGaze(rects []*TextureModelPair, origin, direction rl.Vector3, verticalSize float32) int {
+	target := -1
+	targetDistance := float32(math.Inf(1))
+
+	for i, rect := range rects {
+		// Once stood upright, the plane faces the viewer with its +Y side, and the top of the display is towards -Z
+		normal := toRaylibVector(rect.Rotation.Rotate(headtracking.Up))
+		right := toRaylibVector(rect.Rotation.Rotate(headtracking.Vector3{X: 1, Y: 0, Z: 0}))
+		up := toRaylibVector(rect.Rotation.Rotate(headtracking.Vector3{X: 0, Y: 0, Z: -1}))
+
+		// Looking at the display from behind (or along it) doesn't count
+		facing := rl.Vector3DotProduct(direction, normal)
+
+		if facing >= 0 {
+			continue
+		}
+
+		distance := rl.Vector3DotProduct(rl.Vector3Subtract(rect.WorldPos, origin), normal) / facing
+
+		if distance <= 0 || distance >= targetDistance {
+			continue
+		}
+
+		hit := rl.Vector3Subtract(rl.Vector3Add(origin, rl.Vector3Scale(direction, distance)), rect.WorldPos)
+		halfWidth := findOptimalHorizontalRes(float32(rect.Height), float32(rect.Width), verticalSize) / 2 * rect.Pose.Scale
+		halfHeight := verticalSize / 2 * rect.Pose.Scale
+
+		if math.Abs(float64(rl.Vector3DotProduct(hit, right))) > float64(halfWidth) || math.Abs(float64(rl.Vector3DotProduct(hit, up))) > float64(halfHeight) {
+			continue
+		}
+
+		target = i
+		targetDistance = distance
+	}
+
+	return target
+}
+
+// Draws an outline around a display, scaled up from its center by a factor. Curved displays are outlined as if they were flat
+func drawDisplayOutline(rect *TextureModelPair, verticalSize, scale float32, color color.RGBA) {
+	halfWidth := findOptimalHorizontalRes(float32(rect.Height), float32(rect.Width), verticalSize) / 2 * rect.Pose.Scale * scale
+	halfHeight := verticalSize / 2 * rect.Pose.Scale * scale
+
+	right := rl.Vector3Scale(toRaylibVector(rect.Rotation.Rotate(headtracking.Vector3{X: 1, Y: 0, Z: 0})), halfWidth)
+	up := rl.Vector3Scale(toRaylibVector(rect.Rotation.Rotate(headtracking.Vector3{X: 0, Y: 0, Z: -1})), halfHeight)
+
+	corners := []rl.Vector3{
+		rl.Vector3Add(rect.WorldPos, rl.Vector3Add(right, up)),
+		rl.Vector3Add(rect.WorldPos, rl.Vector3Subtract(right, up)),
+		rl.Vector3Subtract(rect.WorldPos, rl.Vector3Add(right, up)),
+		rl.Vector3Subtract(rect.WorldPos, rl.Vector3Subtract(right, up)),
+	}
+
+	for i, corner := range corners {
+		rl.DrawLine3D(corner, corners[(i+1)%len(corners)], color)
+	}
+}
+
+// Follows which display has focus, which is the one being looked at. Focus only moves once the gaze rests on another display for the dwell time, so glancing across displays doesn't move it
+type focusTracker struct {
+	// Display with focus, or -1 before any display was looked at
+	focused   int
+	candidate int
+	// When the gaze moved onto the candidate
+	candidateSince time.Time
+	dwellTime      time.Duration
+	listeners      []func(previous, focused int)
+}
+
+func newFocusTracker(dwellTime time.Duration) *focusTracker {
+	return &focusTracker{
+		focused:   -1,
+		candidate: -1,
+		dwellTime: dwellTime,
+	}
+}
+
+// Calls a function whenever focus moves. previous is -1 when the first display gets focus
+func (tracker *focusTracker) onChange(listener func(previous, focused int)) {
+	tracker.listeners = append(tracker.listeners, listener)
+}
+
+// Updates focus with the display under the gaze (or -1 if there's none). Looking away from every display keeps focus where it is
+func (tracker *focusTracker) update(target int, now time.Time) {
+	if target == -1 || target == tracker.focused {
+		tracker.candidate = -1
+		return
+	}
+
+	if target != tracker.candidate {
+		tracker.candidate = target
+		tracker.candidateSince = now
+	}
+
+	if now.Sub(tracker.candidateSince) < tracker.dwellTime {
+		return
+	}
+
+	previous := tracker.focused
+	tracker.focused = target
+	tracker.candidate = -1
+
+	for _, listener := range tracker.listeners {
+		listener(previous, tracker.focused)
+	}
+}
@@ -0,0 +1,91 @@
+package renderer
+
+import (
+	"git.lunr.sh/UnrealXR/unrealxr/app/headtracking"
+	"git.lunr.sh/UnrealXR/unrealxr/app/uinput"
+	"github.com/charmbracelet/log"
+)
+
+// Moves the pointer with the head while the clutch is engaged, with a virtual mouse. The view is held still meanwhile, and the pointer is kept on the display it's on, which engaging moves it to.
+type headPointerMode struct {
+	pointer  *uinput.Pointer
+	follower *headtracking.HeadPointer
+	// Pixels the pointer moves per degree of head motion
+	gain      float32
+	isEngaged bool
+	// Orientation the view is held at while engaged
+	heldOrientation headtracking.Quaternion
+	// Motion that hasn't added up to a whole pixel yet, so slow head motion isn't rounded away
+	pendingX float32
+	pendingY float32
+}
+
+func newHeadPointerMode(pointer *uinput.Pointer, gain, deadZoneDegrees float32) *headPointerMode {
+	return &headPointerMode{
+		pointer:  pointer,
+		follower: headtracking.NewHeadPointer(deadZoneDegrees),
+		gain:     gain,
+	}
+}
+
+// Holds the view at an orientation and starts moving the pointer from there
+func (mode *headPointerMode) engage(orientation headtracking.Quaternion) {
+	mode.isEngaged = true
+	mode.heldOrientation = orientation
+	mode.follower.Reset(orientation)
+	mode.pendingX = 0
+	mode.pendingY = 0
+}
+
+func (mode *headPointerMode) disengage() {
+	mode.isEngaged = false
+}
+
+// Adds up the pointer motion of a new head orientation
+func (mode *headPointerMode) follow(orientation headtracking.Quaternion) {
+	right, down := mode.follower.Update(orientation)
+
+	mode.pendingX += right * mode.gain
+	mode.pendingY += down * mode.gain
+}
+
+// Moves the pointer by the whole pixels of motion added up so far, without letting it leave its display
+func (mode *headPointerMode) update(rects []*TextureModelPair, cursors []cursorState, warper *pointerWarper) {
+	if !mode.isEngaged {
+		return
+	}
+
+	dx := int(mode.pendingX)
+	dy := int(mode.pendingY)
+
+	mode.pendingX -= float32(dx)
+	mode.pendingY -= float32(dy)
+
+	if dx == 0 && dy == 0 {
+		return
+	}
+
+	// The motion is dropped while the pointer is still on its way to the focused display, or hidden, as there's no telling where it would end up
+	if warper.isWarping() {
+		return
+	}
+
+	display := pointerDisplay(rects, cursors)
+
+	if display == -1 {
+		return
+	}
+
+	x, y := cursors[display].pointerPosition()
+
+	dx = max(-x, min(rects[display].Width-1-x, dx))
+	dy = max(-y, min(rects[display].Height-1-y, dy))
+
+	if dx == 0 && dy == 0 {
+		return
+	}
+
+	if err := mode.pointer.Move(int32(dx), int32(dy)); err != nil {
+		log.Warnf("Failed to move the pointer: %s", err.Error())
+	}
+}
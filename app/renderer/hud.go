@@ -0,0 +1,245 @@
+package renderer
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	libconfig "git.lunr.sh/UnrealXR/unrealxr/app/config"
+	"git.lunr.sh/UnrealXR/unrealxr/app/headtracking"
+
+	rl "git.lunr.sh/UnrealXR/raylib-go/raylib"
+)
+
+// Most notifications shown at once. Older ones make way for newer ones
+const maxVisibleToasts = 3
+
+// How often the battery is read for its widget
+const batteryRefreshInterval = 30 * time.Second
+
+// Head tracking counts as lost when the driver hasn't reported an orientation for this long
+const imuTimeout = 500 * time.Millisecond
+
+// How far down the head needs to look for "look_down" widgets to show up, in radians
+const widgetLookDownPitch = 20 * math.Pi / 180
+
+// Text size, relative to the height of the view
+const hudFontScale = 1.0 / 36
+
+var (
+	hudTextColor       = rl.White
+	hudWarningColor    = rl.Yellow
+	hudErrorColor      = rl.Red
+	hudBackgroundColor = color.RGBA{R: 20, G: 20, B: 20, A: 200}
+)
+
+// How important a notification is, which decides its color
+type toastLevel int
+
+const (
+	toastInfo toastLevel = iota
+	toastWarning
+	toastError
+)
+
+type toast struct {
+	message string
+	level   toastLevel
+	shownAt time.Time
+}
+
+// Head-locked overlay that shows notifications and status widgets in screen space, on top of everything else.
+// Notifications can come from any goroutine
+type hud struct {
+	isEnabled     bool
+	toastDuration time.Duration
+	fadeDuration  time.Duration
+	widgets       []string
+	showWidgets   string
+	tracker       *headtracking.Tracker
+
+	lock   sync.Mutex
+	toasts []toast
+
+	// Shown until it's cleared, like while waiting for the sensors
+	status string
+	// Opacity of the widgets, which fade in and out in "look_down" mode
+	widgetOpacity float32
+
+	battery          string
+	batteryUpdatedAt time.Time
+}
+
+func newHUD(hudConfig libconfig.HUDConfig, tracker *headtracking.Tracker) *hud {
+	return &hud{
+		isEnabled:     *hudConfig.Enabled,
+		toastDuration: time.Duration(*hudConfig.ToastDuration * float32(time.Second)),
+		fadeDuration:  time.Duration(*hudConfig.FadeDuration * float32(time.Second)),
+		widgets:       hudConfig.Widgets,
+		showWidgets:   *hudConfig.ShowWidgets,
+		tracker:       tracker,
+	}
+}
+
+// Shows a notification. Safe to call from any goroutine
+func (hud *hud) toast(level toastLevel, format string, args ...any) {
+	hud.lock.Lock()
+	defer hud.lock.Unlock()
+
+	hud.toasts = append(hud.toasts, toast{
+		message: fmt.Sprintf(format, args...),
+		level:   level,
+		shownAt: time.Now(),
+	})
+}
+
+// Shows a message until it's replaced or cleared with an empty one
+func (hud *hud) setStatus(status string) {
+	hud.status = status
+}
+
+// Gets how visible something is that fades in at start and out at end
+func (hud *hud) fade(now, start, end time.Time) float32 {
+	if hud.fadeDuration <= 0 {
+		return 1
+	}
+
+	fadeIn := float32(now.Sub(start)) / float32(hud.fadeDuration)
+	fadeOut := float32(end.Sub(now)) / float32(hud.fadeDuration)
+
+	return max(0, min(1, fadeIn, fadeOut))
+}
+
+// Drops expired notifications and fades the widgets in or out, depending on where the head is looking
+func (hud *hud) update(orientation headtracking.Quaternion, elapsed time.Duration, now time.Time) {
+	hud.lock.Lock()
+
+	hud.toasts = slices.DeleteFunc(hud.toasts, func(toast toast) bool {
+		return now.Sub(toast.shownAt) >= hud.toastDuration
+	})
+
+	hud.lock.Unlock()
+
+	targetOpacity := float32(1)
+
+	if hud.showWidgets == libconfig.HUDShowLookDown && orientation.Pitch() > -widgetLookDownPitch {
+		targetOpacity = 0
+	}
+
+	if hud.fadeDuration <= 0 {
+		hud.widgetOpacity = targetOpacity
+	} else if step := float32(elapsed) / float32(hud.fadeDuration); hud.widgetOpacity < targetOpacity {
+		hud.widgetOpacity = min(targetOpacity, hud.widgetOpacity+step)
+	} else {
+		hud.widgetOpacity = max(targetOpacity, hud.widgetOpacity-step)
+	}
+
+	if slices.Contains(hud.widgets, libconfig.HUDWidgetBattery) && now.Sub(hud.batteryUpdatedAt) >= batteryRefreshInterval {
+		hud.batteryUpdatedAt = now
+		hud.battery = ""
+
+		if percent, isCharging, ok := readBattery(); ok && isCharging {
+			hud.battery = fmt.Sprintf("%d%% charging", percent)
+		} else if ok {
+			hud.battery = fmt.Sprintf("%d%%", percent)
+		}
+	}
+}
+
+// Gets the text of a widget, or "" if there is nothing to show
+func (hud *hud) widgetText(widget string, now time.Time) string {
+	switch widget {
+	case libconfig.HUDWidgetClock:
+		return now.Format("15:04")
+
+	case libconfig.HUDWidgetFPS:
+		return fmt.Sprintf("%d FPS", rl.GetFPS())
+
+	case libconfig.HUDWidgetIMU:
+		lastReading, ok := hud.tracker.LastReading()
+
+		if !ok {
+			return "IMU waiting"
+		} else if now.Sub(lastReading) > imuTimeout {
+			return "IMU lost"
+		}
+
+		return "IMU ok"
+
+	case libconfig.HUDWidgetBattery:
+		return hud.battery
+	}
+
+	return ""
+}
+
+// Scales the alpha of a color by an opacity
+func withOpacity(original color.RGBA, opacity float32) color.RGBA {
+	original.A = uint8(float32(original.A) * opacity)
+	return original
+}
+
+// Draws a line of text on a box, centered horizontally around x
+func drawHUDLabel(text string, x, y, fontSize int32, textColor color.RGBA, opacity float32) {
+	padding := fontSize / 3
+	width := rl.MeasureText(text, fontSize)
+
+	rl.DrawRectangle(x-width/2-padding, y-padding, width+2*padding, fontSize+2*padding, withOpacity(hudBackgroundColor, opacity))
+	rl.DrawText(text, x-width/2, y, fontSize, withOpacity(textColor, opacity))
+}
+
+// Draws the HUD into a view at the given position and size on the screen. In stereo mode, this happens once per eye, so it appears at the convergence distance
+func (hud *hud) draw(x, y, width, height int32, now time.Time) {
+	if !hud.isEnabled {
+		return
+	}
+
+	fontSize := int32(float32(height) * hudFontScale)
+	lineHeight := fontSize * 2
+	centerX := x + width/2
+	lineY := y + height/10
+
+	if hud.status != "" {
+		drawHUDLabel(hud.status, centerX, lineY, fontSize, hudWarningColor, 1)
+		lineY += lineHeight
+	}
+
+	hud.lock.Lock()
+	visibleToasts := hud.toasts[max(0, len(hud.toasts)-maxVisibleToasts):]
+
+	for _, toast := range visibleToasts {
+		textColor := hudTextColor
+
+		switch toast.level {
+		case toastWarning:
+			textColor = hudWarningColor
+		case toastError:
+			textColor = hudErrorColor
+		}
+
+		drawHUDLabel(toast.message, centerX, lineY, fontSize, textColor, hud.fade(now, toast.shownAt, toast.shownAt.Add(hud.toastDuration)))
+		lineY += lineHeight
+	}
+
+	hud.lock.Unlock()
+
+	if hud.widgetOpacity <= 0 {
+		return
+	}
+
+	texts := make([]string, 0, len(hud.widgets))
+
+	for _, widget := range hud.widgets {
+		if text := hud.widgetText(widget, now); text != "" {
+			texts = append(texts, text)
+		}
+	}
+
+	if len(texts) != 0 {
+		drawHUDLabel(strings.Join(texts, "   "), centerX, y+height-height/10-fontSize, fontSize, hudTextColor, hud.widgetOpacity)
+	}
+}
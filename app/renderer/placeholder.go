@@ -0,0 +1,28 @@
+package renderer
+
+import (
+	rl "git.lunr.sh/UnrealXR/raylib-go/raylib"
+)
+
+// Tint used for displays that are turned off with the "dimmed" blanked behavior
+var dimmedDisplayTint = rl.NewColor(60, 60, 60, 255)
+
+// Creates the texture shown in place of displays that are turned off with the "placeholder" blanked behavior
+func loadDisplayOffPlaceholder() rl.Texture2D {
+	const (
+		width    = 640
+		height   = 360
+		fontSize = 40
+		text     = "Display off"
+	)
+
+	image := rl.GenImageColor(width, height, rl.NewColor(20, 20, 20, 255))
+	textWidth := rl.MeasureText(text, fontSize)
+
+	rl.ImageDrawText(image, (width-textWidth)/2, (height-fontSize)/2, text, fontSize, rl.Gray)
+
+	texture := rl.LoadTextureFromImage(image)
+	rl.UnloadImage(image)
+
+	return texture
+}
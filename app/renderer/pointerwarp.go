@@ -0,0 +1,104 @@
+package renderer
+
+import (
+	"time"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/uinput"
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// How long a warp keeps correcting the pointer before giving up, in case it can't reach the display
+	pointerWarpTimeout = 500 * time.Millisecond
+	// How long to wait for the pointer to show up where it was moved to before correcting it again
+	pointerWarpSettleTime = 50 * time.Millisecond
+)
+
+// Moves the pointer onto the focused display with a virtual mouse.
+//
+// We can't see how the compositor arranges the virtual displays, so they're assumed to be next to each other from left to right in display order.
+// Pointer acceleration also makes relative motion inexact, so the pointer is corrected until it is on the display, watching where the displays' cursors end up.
+type pointerWarper struct {
+	pointer *uinput.Pointer
+	// Display the pointer is being moved to, or -1
+	target   int
+	deadline time.Time
+	lastMove time.Time
+}
+
+func newPointerWarper(pointer *uinput.Pointer) *pointerWarper {
+	return &pointerWarper{
+		pointer: pointer,
+		target:  -1,
+	}
+}
+
+// Starts moving the pointer to a display, unless it's already there
+func (warper *pointerWarper) warpTo(display int, now time.Time) {
+	warper.target = display
+	warper.deadline = now.Add(pointerWarpTimeout)
+	warper.lastMove = time.Time{}
+}
+
+func (warper *pointerWarper) isWarping() bool {
+	return warper.target != -1
+}
+
+// Finds the display that shows the pointer. Returns -1 if it's hidden, like while a video plays in fullscreen
+func pointerDisplay(rects []*TextureModelPair, cursors []cursorState) int {
+	for display, cursor := range cursors {
+		if cursor.enabled && rects[display].IsDisplayOn {
+			return display
+		}
+	}
+
+	return -1
+}
+
+// Moves the pointer a step closer to the display it's being warped to
+func (warper *pointerWarper) update(rects []*TextureModelPair, cursors []cursorState, now time.Time) {
+	if warper.target == -1 || now.Sub(warper.lastMove) < pointerWarpSettleTime {
+		return
+	}
+
+	if now.After(warper.deadline) {
+		log.Debugf("Gave up moving the pointer to display #%d", warper.target)
+		warper.target = -1
+		return
+	}
+
+	source := pointerDisplay(rects, cursors)
+
+	if source == -1 {
+		return
+	}
+
+	if source == warper.target {
+		warper.target = -1
+		return
+	}
+
+	x, y := cursors[source].pointerPosition()
+
+	// Aim for the center of the target display, going across every display in between
+	dx := rects[warper.target].Width/2 - x
+	dy := rects[warper.target].Height/2 - y
+
+	if source < warper.target {
+		for display := source; display < warper.target; display++ {
+			dx += rects[display].Width
+		}
+	} else {
+		for display := warper.target; display < source; display++ {
+			dx -= rects[display].Width
+		}
+	}
+
+	if err := warper.pointer.Move(int32(dx), int32(dy)); err != nil {
+		log.Warnf("Failed to move the pointer: %s", err.Error())
+		warper.target = -1
+		return
+	}
+
+	warper.lastMove = now
+}
@@ -0,0 +1,139 @@
+package renderer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	libconfig "git.lunr.sh/UnrealXR/unrealxr/app/config"
+	"github.com/charmbracelet/log"
+
+	rl "git.lunr.sh/UnrealXR/raylib-go/raylib"
+)
+
+// How display textures are sampled
+type textureQuality struct {
+	filter rl.TextureFilterMode
+	// Anisotropic filter level, or 0 if it's off
+	anisotropy rl.TextureFilterMode
+	hasMipmaps bool
+}
+
+func newTextureQuality(rendering libconfig.RenderingConfig) textureQuality {
+	quality := textureQuality{
+		filter: rl.FilterPoint,
+	}
+
+	switch *rendering.TextureFilter {
+	case libconfig.TextureFilterBilinear:
+		quality.filter = rl.FilterBilinear
+	case libconfig.TextureFilterTrilinear:
+		quality.filter = rl.FilterTrilinear
+		quality.hasMipmaps = true
+	}
+
+	switch *rendering.Anisotropy {
+	case 4:
+		quality.anisotropy = rl.FilterAnisotropic4x
+	case 8:
+		quality.anisotropy = rl.FilterAnisotropic8x
+	case 16:
+		quality.anisotropy = rl.FilterAnisotropic16x
+	}
+
+	return quality
+}
+
+// Sets up how a newly loaded texture is sampled
+func (quality textureQuality) apply(texture *rl.Texture2D) {
+	// Trilinear filtering falls back to bilinear without mipmaps, so they need to exist first
+	if quality.hasMipmaps {
+		rl.GenTextureMipmaps(texture)
+	}
+
+	rl.SetTextureFilter(*texture, quality.filter)
+
+	// Anisotropic filtering is set on its own, on top of the filter
+	if quality.anisotropy != 0 {
+		rl.SetTextureFilter(*texture, quality.anisotropy)
+	}
+}
+
+// Brings the mipmaps of a texture up to date after it changed. Returns how long that took
+func (quality textureQuality) refresh(texture *rl.Texture2D) time.Duration {
+	if !quality.hasMipmaps {
+		return 0
+	}
+
+	start := time.Now()
+	rl.GenTextureMipmaps(texture)
+
+	return time.Since(start)
+}
+
+// Keeps track of how long the costlier parts of rendering take, so the quality settings can be weighed against them.
+// Times are measured on the CPU. Work the GPU does later, like multisampling, only shows up as a lower frame rate
+type renderStats struct {
+	// Quality settings in use, as shown in the log
+	features string
+
+	frames int
+	// Time spent drawing the scene, for every eye
+	sceneTime time.Duration
+	// Time spent regenerating mipmaps of updated textures
+	mipmapTime time.Duration
+	// Time spent scaling supersampled frames down
+	downsampleTime time.Duration
+	since          time.Time
+}
+
+func newRenderStats(rendering libconfig.RenderingConfig) *renderStats {
+	features := []string{*rendering.TextureFilter + " filtering"}
+
+	if *rendering.Anisotropy != 0 {
+		features = append(features, fmt.Sprintf("%dx anisotropy", *rendering.Anisotropy))
+	}
+
+	if *rendering.MSAA {
+		features = append(features, "4x MSAA")
+	}
+
+	if *rendering.Supersampling > 1 {
+		features = append(features, fmt.Sprintf("%gx supersampling", *rendering.Supersampling))
+	}
+
+	return &renderStats{
+		features: strings.Join(features, ", "),
+		since:    time.Now(),
+	}
+}
+
+// Records that a frame was rendered, and logs the statistics every once in a while
+func (stats *renderStats) recordFrame() {
+	stats.frames++
+
+	elapsed := time.Since(stats.since)
+
+	if elapsed < uploadStatsInterval {
+		return
+	}
+
+	perFrame := func(total time.Duration) float64 {
+		return float64(total.Microseconds()) / 1000 / float64(stats.frames)
+	}
+
+	log.Debugf(
+		"rendering (%s): %.01f frames/s, %.02f ms/frame drawing, %.02f ms/frame regenerating mipmaps, %.02f ms/frame downsampling",
+		stats.features,
+		float64(stats.frames)/elapsed.Seconds(),
+		perFrame(stats.sceneTime),
+		perFrame(stats.mipmapTime),
+		perFrame(stats.downsampleTime),
+	)
+
+	stats.frames = 0
+	stats.sceneTime = 0
+	stats.mipmapTime = 0
+	stats.downsampleTime = 0
+	stats.since = time.Now()
+}
@@ -1,15 +1,25 @@
 package renderer
 
 import (
+	"errors"
+	"fmt"
 	"image/color"
 	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 	"unsafe"
 
 	libconfig "git.lunr.sh/UnrealXR/unrealxr/app/config"
 	"git.lunr.sh/UnrealXR/unrealxr/app/edidtools"
+	"git.lunr.sh/UnrealXR/unrealxr/app/headtracking"
+	"git.lunr.sh/UnrealXR/unrealxr/app/layout"
+	"git.lunr.sh/UnrealXR/unrealxr/app/uinput"
 	"git.lunr.sh/UnrealXR/unrealxr/ardriver"
 	arcommons "git.lunr.sh/UnrealXR/unrealxr/ardriver/commons"
+	"git.lunr.sh/UnrealXR/unrealxr/evdi/libevdi"
 	"github.com/charmbracelet/log"
 	"github.com/tebeka/atexit"
 
@@ -17,12 +27,43 @@ import (
 )
 
 type TextureModelPair struct {
-	Texture               rl.Texture2D
-	Model                 rl.Model
-	CurrentAngle          float32
-	CurrentDisplaySpacing float32
+	Texture rl.Texture2D
+	Model   rl.Model
+	Width   int
+	Height  int
+	// Pose of the display in the layout, before anchoring moves it along with the head
+	Pose   layout.Pose
+	Anchor *headtracking.Anchor
+	// Radius of the display's curve, or 0 if it's flat
+	CurveRadius float32
+	// How much the display is zoomed in, by moving it towards the viewer
+	Magnification float32
+	// Where and how the display is drawn in the current frame
+	WorldPos       rl.Vector3
+	Rotation       headtracking.Quaternion
+	AnchorRotation headtracking.Quaternion
+	IsDisplayOn    bool
+	// Whether capturing the display failed, so it shows the placeholder until it recovers
+	HasFailed bool
 }
 
+// Creates the texture and plane of a display with the given resolution. The plane always has the same height, and its width follows the aspect ratio
+func loadDisplayModel(width, height int, verticalSize, curveRadius float32, quality textureQuality) (rl.Texture2D, rl.Model) {
+	image := rl.NewImage(make([]byte, width*height*libevdi.StridePixelFormatRGBA32), int32(width), int32(height), 1, rl.UncompressedR8g8b8a8)
+	texture := rl.LoadTextureFromImage(image)
+	quality.apply(&texture)
+
+	horizontalSize := findOptimalHorizontalRes(float32(height), float32(width), verticalSize)
+	model := rl.LoadModelFromMesh(genDisplayMesh(horizontalSize, verticalSize, curveRadius))
+
+	rl.SetMaterialTexture(model.Materials, rl.MapAlbedo, texture)
+
+	return texture, model
+}
+
+// Distance from the camera to the displays, in world units
+const cameraDistance = 5.0
+
 func findMaxVerticalSize(fovyDeg float32, distance float32) float32 {
 	fovyRad := float64(fovyDeg * math.Pi / 180.0)
 	return 2 * distance * float32(math.Tan(fovyRad/2))
@@ -44,9 +85,117 @@ func findHfovFromVfov(vfovDeg, w, h float64) float64 {
 	return hfovRad * 180 / math.Pi
 }
 
-func EnterRenderLoop(config *libconfig.Config, displayMetadata *edidtools.DisplayMetadata, evdiCards []*EvdiDisplayMetadata) {
+func bytesToPixels(pixelBytes []byte) []color.RGBA {
+	return unsafe.Slice(
+		(*color.RGBA)(unsafe.Pointer(&pixelBytes[0])),
+		len(pixelBytes)/4,
+	)
+}
+
+// Uploads the damaged regions of a frame to a texture
+func uploadDamage(texture rl.Texture2D, frame *capturedFrame, damage []damageRect, uploadBuffer *[]byte) {
+	buffer := frame.buffer
+
+	for _, rect := range damage {
+		if rect.X1 == 0 && rect.Y1 == 0 && rect.X2 == buffer.Width && rect.Y2 == buffer.Height {
+			rl.UpdateTexture(texture, bytesToPixels(buffer.Buffer))
+			continue
+		}
+
+		*uploadBuffer = packDamage(*uploadBuffer, buffer.Buffer, buffer.Width, rect)
+
+		rl.UpdateTextureRec(texture, rl.Rectangle{
+			X:      float32(rect.X1),
+			Y:      float32(rect.Y1),
+			Width:  float32(rect.X2 - rect.X1),
+			Height: float32(rect.Y2 - rect.Y1),
+		}, bytesToPixels(*uploadBuffer))
+	}
+}
+
+// Gets the raylib key for a key binding from the config ("f1" to "f12"). Returns 0 for "none"
+func keyFromName(name string) int32 {
+	functionKey, err := strconv.Atoi(strings.TrimPrefix(name, "f"))
+
+	if err != nil || !strings.HasPrefix(name, "f") {
+		return 0
+	}
+
+	return rl.KeyF1 + int32(functionKey) - 1
+}
+
+// Gets the anchor mode of a display, which can be overridden per display
+func anchorModeFor(config *libconfig.Config, display int) headtracking.AnchorMode {
+	anchoring := *config.DisplayConfig.Anchoring
+
+	if displayAnchoring, ok := config.DisplayConfig.DisplayAnchoring[display]; ok {
+		anchoring = displayAnchoring
+	}
+
+	switch anchoring {
+	case libconfig.AnchoringHead:
+		return headtracking.AnchorHead
+	case libconfig.AnchoringFollow:
+		return headtracking.AnchorFollow
+	default:
+		return headtracking.AnchorWorld
+	}
+}
+
+// Creates the filter for a single axis of head tracking
+func angleFilterFromConfig(axis libconfig.AxisFilterConfig) headtracking.AngleFilter {
+	switch *axis.Filter {
+	case libconfig.FilterExponential:
+		return headtracking.NewExponentialFilter(float64(*axis.MinCutoff))
+	case libconfig.FilterOneEuro:
+		return headtracking.NewOneEuroFilter(float64(*axis.MinCutoff), float64(*axis.Beta))
+	default:
+		return &headtracking.PassthroughFilter{}
+	}
+}
+
+// Converts a display pose from the config into a layout override
+func poseOverrideFromConfig(pose *libconfig.DisplayPoseConfig) layout.PoseOverride {
+	override := layout.PoseOverride{
+		Scale: pose.Scale,
+	}
+
+	if pose.Position != nil {
+		override.Position = &headtracking.Vector3{
+			X: pose.Position[0],
+			Y: pose.Position[1],
+			Z: pose.Position[2],
+		}
+	}
+
+	if pose.Rotation != nil {
+		override.Rotation = &[3]float32{pose.Rotation[0], pose.Rotation[1], pose.Rotation[2]}
+	}
+
+	return override
+}
+
+// Points the camera in the direction of an orientation
+func updateCameraFromOrientation(camera *rl.Camera3D, orientation headtracking.Quaternion) {
+	forward := orientation.Rotate(headtracking.Forward)
+	up := orientation.Rotate(headtracking.Up)
+
+	camera.Target = rl.Vector3{
+		X: camera.Position.X + forward.X,
+		Y: camera.Position.Y + forward.Y,
+		Z: camera.Position.Z + forward.Z,
+	}
+
+	camera.Up = rl.Vector3{
+		X: up.X,
+		Y: up.Y,
+		Z: up.Z,
+	}
+}
+
+func EnterRenderLoop(config *libconfig.Config, configDir string, displayMetadata *edidtools.DisplayMetadata, evdiCards []*EvdiDisplayMetadata, pointer *uinput.Pointer, xrDeviceNodes []*os.File) {
 	log.Info("Initializing AR driver")
-	headset, err := ardriver.GetDevice()
+	headset, err := ardriver.GetDevice(xrDeviceNodes)
 
 	if err != nil {
 		log.Errorf("Failed to get device: %s", err.Error())
@@ -55,50 +204,80 @@ func EnterRenderLoop(config *libconfig.Config, displayMetadata *edidtools.Displa
 
 	log.Info("Initialized")
 
-	var (
-		currentPitch  float32
-		previousPitch float32
-		currentYaw    float32
-		previousYaw   float32
-		currentRoll   float32
-		previousRoll  float32
-
-		hasGottenPitchCallbackBefore bool
-		hasGottenYawCallbackBefore   bool
-		hasGottenRollCallbackBefore  bool
+	var orientationFilter *headtracking.OrientationFilter
+	filterConfig := config.HeadTracking.Filter
+
+	// Splitting orientations up into angles isn't free and gets imprecise when looking straight up or down, so skip it when there's nothing to filter
+	if *filterConfig.Yaw.Filter != libconfig.FilterNone || *filterConfig.Pitch.Filter != libconfig.FilterNone || *filterConfig.Roll.Filter != libconfig.FilterNone {
+		orientationFilter = headtracking.NewOrientationFilter(
+			angleFilterFromConfig(filterConfig.Yaw),
+			angleFilterFromConfig(filterConfig.Pitch),
+			angleFilterFromConfig(filterConfig.Roll),
+		)
+	}
+
+	tracker := headtracking.NewTracker(
+		*config.HeadTracking.RecenterPitch,
+		time.Duration(*config.HeadTracking.RecenterDuration*float32(time.Second)),
+		orientationFilter,
 	)
 
+	// Notifications show up in the headset as well as in the log, as the terminal can't be seen while wearing the glasses
+	hudOverlay := newHUD(config.HUD, tracker)
+
+	if len(config.Profiles) > 1 {
+		hudOverlay.toast(toastInfo, "Profile '%s'", *config.Profile)
+	}
+
+	background, err := loadBackground(libconfig.ActiveProfile(config).Background, configDir)
+
+	if err != nil {
+		log.Errorf("Failed to load the background, falling back to black: %s", err.Error())
+		hudOverlay.toast(toastError, "Failed to load the background")
+		background, _ = loadBackground(libconfig.DefaultProfile.Background, configDir)
+	}
+
+	// Presses of the clutch button, which are handled in the render loop
+	clutchPresses := make(chan struct{}, 1)
+
 	arEventListner := &arcommons.AREventListener{
-		PitchCallback: func(newPitch float32) {
-			if !hasGottenPitchCallbackBefore {
-				hasGottenPitchCallbackBefore = true
-				currentPitch = newPitch
-				previousPitch = newPitch
-			} else {
-				previousPitch = currentPitch
-				currentPitch = newPitch
+		OrientationCallback: tracker.Update,
+		// Runs with the device's event lock held, so anything that needs the device goes through the render loop (like clutchPresses)
+		ButtonCallback: func(button arcommons.ARButton) {
+			if recenterButton, ok := arcommons.ARButtonNames[*config.HeadTracking.RecenterButton]; ok && button == recenterButton {
+				log.Debug("Recentering (glasses button)")
+				tracker.Recenter()
+				hudOverlay.toast(toastInfo, "Recentered")
 			}
-		},
-		YawCallback: func(newYaw float32) {
-			if !hasGottenYawCallbackBefore {
-				hasGottenYawCallbackBefore = true
-				currentYaw = newYaw
-				previousYaw = newYaw
-			} else {
-				previousYaw = currentYaw
-				currentYaw = newYaw
-			}
-		},
-		RollCallback: func(newRoll float32) {
-			if !hasGottenRollCallbackBefore {
-				hasGottenRollCallbackBefore = true
-				currentRoll = newRoll
-				previousRoll = newRoll
-			} else {
-				previousRoll = currentRoll
-				currentRoll = newRoll
+
+			if clutchButton, ok := arcommons.ARButtonNames[*config.HeadPointer.ClutchButton]; ok && button == clutchButton {
+				select {
+				case clutchPresses <- struct{}{}:
+				default:
+				}
 			}
 		},
+		BlendStateCallback: background.setBlendState,
+	}
+
+	recenterSignals := make(chan os.Signal, 1)
+	notifyOnRecenterSignal(recenterSignals)
+
+	go func() {
+		for range recenterSignals {
+			log.Debug("Recentering (signal)")
+			tracker.Recenter()
+			hudOverlay.toast(toastInfo, "Recentered")
+		}
+	}()
+
+	var autoRecenter *headtracking.AutoRecenter
+
+	if *config.HeadTracking.AutoRecenter {
+		autoRecenter = headtracking.NewAutoRecenter(
+			float32(*config.HeadTracking.AutoRecenterAngle),
+			time.Duration(*config.HeadTracking.AutoRecenterDelay*float32(time.Second)),
+		)
 	}
 
 	if headset.IsPollingLibrary() {
@@ -108,16 +287,41 @@ func EnterRenderLoop(config *libconfig.Config, displayMetadata *edidtools.Displa
 
 	headset.RegisterEventListeners(arEventListner)
 
+	var stereo *stereoRenderer
+
+	if stereoConfig := libconfig.ActiveProfile(config).Stereo; *stereoConfig.Enabled {
+		stereo, err = enableStereo(headset, stereoConfig, displayMetadata, *config.Rendering.Supersampling)
+
+		if err != nil {
+			log.Errorf("Failed to enable stereo rendering, falling back to mono: %s", err.Error())
+			hudOverlay.toast(toastError, "Failed to enable stereo rendering")
+		}
+	}
+
+	// Stereo rendering supersamples each eye on its own
+	var supersampling *supersampler
+
+	if stereo == nil && *config.Rendering.Supersampling > 1 {
+		supersampling = newSupersampler(displayMetadata.MaxWidth, displayMetadata.MaxHeight, *config.Rendering.Supersampling)
+	}
+
+	if *config.Rendering.MSAA && (stereo != nil || supersampling != nil) {
+		log.Warn("MSAA only applies when drawing straight to the glasses, so it has no effect with stereo rendering or supersampling")
+	}
+
+	quality := newTextureQuality(config.Rendering)
+	renderStatistics := newRenderStats(config.Rendering)
+
 	fovY := float32(*config.DisplayConfig.FOV)
 	fovX := findHfovFromVfov(float64(fovY), float64(displayMetadata.MaxWidth), float64(displayMetadata.MaxHeight))
 
-	verticalSize := findMaxVerticalSize(fovY, 5.0)
+	verticalSize := findMaxVerticalSize(fovY, cameraDistance)
 
 	camera := rl.NewCamera3D(
 		rl.Vector3{
 			X: 0.0,
 			Y: verticalSize / 2,
-			Z: 5.0,
+			Z: cameraDistance,
 		},
 		rl.Vector3{
 			X: 0.0,
@@ -133,34 +337,37 @@ func EnterRenderLoop(config *libconfig.Config, displayMetadata *edidtools.Displa
 		rl.CameraPerspective,
 	)
 
-	horizontalSize := findOptimalHorizontalRes(float32(displayMetadata.MaxHeight), float32(displayMetadata.MaxWidth), verticalSize)
-	coreMesh := rl.GenMeshPlane(horizontalSize, verticalSize, 1, 1)
-
-	var radius float32
+	// The layout is arranged around where the camera starts out
+	viewerPosition := camera.Position
 
-	if *config.DisplayConfig.UseCircularSpacing == true {
-		radiusX := (horizontalSize / 2) / float32(math.Tan((float64(fovX)*math.Pi/180.0)/2))
-		radiusY := (verticalSize / 2) / float32(math.Tan((float64(fovY)*math.Pi/180.0)/2))
+	horizontalSize := findOptimalHorizontalRes(float32(displayMetadata.MaxHeight), float32(displayMetadata.MaxWidth), verticalSize)
+	profile := libconfig.ActiveProfile(config)
+
+	layoutParameters := layout.Parameters{
+		Count:    len(evdiCards),
+		Distance: cameraDistance,
+		Width:    horizontalSize,
+		Height:   verticalSize,
+		Spacing:  *config.DisplayConfig.Spacing,
+		Angle:    float32(*config.DisplayConfig.Angle),
+		Radius:   layout.FittingRadius(horizontalSize, verticalSize, float32(fovX), fovY) * *config.DisplayConfig.RadiusMultiplier,
+		Columns:  *profile.Layout.Columns,
+	}
 
-		if radiusY > radiusX {
-			radius = radiusY
-		} else {
-			radius = radiusX
-		}
+	poses, err := layout.Generate(*profile.Layout.Type, layoutParameters)
 
-		radius *= *config.DisplayConfig.RadiusMultiplier
+	if err != nil {
+		log.Errorf("Failed to generate layout: %s", err.Error())
+		atexit.Exit(1)
 	}
 
-	movementVector := rl.Vector3{
-		X: 0.0,
-		Y: 0.0,
-		Z: 0.0,
-	}
+	// The layout editor resets displays to what the layout generated
+	editor := newLayoutEditor(slices.Clone(poses), *config.DisplayConfig.SnapAngle)
 
-	lookVector := rl.Vector3{
-		X: 0.0,
-		Y: 0.0,
-		Z: 0.0,
+	for display, pose := range profile.Layout.Displays {
+		if pose != nil {
+			poses[display] = poses[display].WithOverride(poseOverrideFromConfig(pose))
+		}
 	}
 
 	hasZVectorDisabledQuirk := false
@@ -169,6 +376,7 @@ func EnterRenderLoop(config *libconfig.Config, displayMetadata *edidtools.Displa
 
 	if displayMetadata.DeviceQuirks.ZVectorDisabled {
 		log.Warn("QUIRK: The Z vector has been disabled for your specific device")
+		hudOverlay.toast(toastWarning, "Head roll is disabled for this device")
 		hasZVectorDisabledQuirk = true
 	}
 
@@ -180,156 +388,423 @@ func EnterRenderLoop(config *libconfig.Config, displayMetadata *edidtools.Displa
 
 	rects := make([]*TextureModelPair, len(evdiCards))
 
-	displayAngle := float32(*config.DisplayConfig.Angle)
-	displaySpacing := *config.DisplayConfig.Spacing + horizontalSize
+	for i, card := range evdiCards {
+		log.Debugf("display #%d: position=%+v, rotation=%+v, scale=%f", i, poses[i].Position, poses[i].Rotation, poses[i].Scale)
+
+		if _, err := startCapture(card, displayMetadata.MaxWidth, displayMetadata.MaxHeight); err != nil {
+			log.Errorf("Failed to start capturing display #%d: %s", i, err.Error())
+			atexit.Exit(1)
+		}
 
-	highestPossibleAngleOnBothSides := float32((*config.DisplayConfig.Count)-1) * displayAngle
-	highestPossibleDisplaySpacingOnBothSides := float32((*config.DisplayConfig.Count)-1) * displaySpacing
+		curveRadius := float32(0)
+		isCurved, ok := config.DisplayConfig.DisplayCurved[i]
 
-	for i, card := range evdiCards {
-		currentAngle := (-highestPossibleAngleOnBothSides) + (displayAngle * float32(i+1))
-		currentDisplaySpacing := (-highestPossibleDisplaySpacingOnBothSides) + (displaySpacing * float32(i+1))
+		if !ok {
+			isCurved = *config.DisplayConfig.Curved
+		}
 
-		log.Debugf("display #%d: currentAngle=%f, currentDisplaySpacing=%f", i, currentAngle, currentDisplaySpacing)
+		if isCurved {
+			curveRadius = *config.DisplayConfig.CurveRadius
 
-		image := rl.NewImage(card.Buffer.Buffer, int32(displayMetadata.MaxWidth), int32(displayMetadata.MaxHeight), 1, rl.UncompressedR8g8b8a8)
+			// Without a radius set, follow the sphere of the layout, or the distance to the displays if there is none
+			if curveRadius == 0 && *profile.Layout.Type != layout.KindRow {
+				curveRadius = layoutParameters.Radius
+			} else if curveRadius == 0 {
+				curveRadius = cameraDistance
+			}
+		}
 
-		texture := rl.LoadTextureFromImage(image)
-		model := rl.LoadModelFromMesh(coreMesh)
+		texture, model := loadDisplayModel(displayMetadata.MaxWidth, displayMetadata.MaxHeight, verticalSize, curveRadius, quality)
 
-		// spin up/down
-		pitchRad := float32(-90 * rl.Deg2rad)
-		// spin left/right
-		yawRad := currentAngle * rl.Deg2rad
+		rects[i] = &TextureModelPair{
+			Texture:       texture,
+			Model:         model,
+			Width:         displayMetadata.MaxWidth,
+			Height:        displayMetadata.MaxHeight,
+			Pose:          poses[i],
+			CurveRadius:   curveRadius,
+			Magnification: 1,
+			Anchor: headtracking.NewAnchor(
+				anchorModeFor(config, i),
+				float32(*config.DisplayConfig.FollowDeadZone),
+				time.Duration(*config.DisplayConfig.FollowEasing*float32(time.Second)),
+			),
+		}
+	}
 
-		rotX := rl.MatrixRotateX(pitchRad)
-		rotY := rl.MatrixRotateY(yawRad)
+	uploadStatistics := make([]*uploadStats, len(evdiCards))
 
-		transform := rl.MatrixMultiply(rotX, rotY)
-		model.Transform = transform
+	for i := range evdiCards {
+		uploadStatistics[i] = newUploadStats(i)
+	}
 
-		rl.SetMaterialTexture(model.Materials, rl.MapAlbedo, texture)
+	cursors := make([]cursorState, len(evdiCards))
+	cursorOverlays := make([]*cursorOverlay, len(evdiCards))
 
-		rects[i] = &TextureModelPair{
-			Texture:               texture,
-			Model:                 model,
-			CurrentAngle:          currentAngle,
-			CurrentDisplaySpacing: currentDisplaySpacing,
+	for i := range evdiCards {
+		cursorOverlays[i] = &cursorOverlay{}
+	}
+
+	placeholderTexture := loadDisplayOffPlaceholder()
+	quality.apply(&placeholderTexture)
+	isIdle := false
+
+	recenterKey := keyFromName(*config.HeadTracking.RecenterKey)
+	editKey := keyFromName(*config.DisplayConfig.EditKey)
+	clutchKey := keyFromName(*config.HeadPointer.ClutchKey)
+	zoomInKey := keyFromName(*config.Zoom.InKey)
+	zoomOutKey := keyFromName(*config.Zoom.OutKey)
+	pixelPerfectKey := keyFromName(*config.Zoom.PixelPerfectKey)
+
+	zoom := newZoomController(len(rects), config.Zoom)
+
+	focus := newFocusTracker(time.Duration(*config.Focus.DwellTime * float32(time.Second)))
+
+	focus.onChange(func(previous, focused int) {
+		log.Debugf("Focus moved to display #%d", focused)
+	})
+
+	var warper *pointerWarper
+	var headPointer *headPointerMode
+
+	if pointer != nil {
+		warper = newPointerWarper(pointer)
+
+		if *config.Focus.PointerWarp == libconfig.PointerWarpUinput {
+			focus.onChange(func(previous, focused int) {
+				warper.warpTo(focused, time.Now())
+			})
+		}
+
+		if *config.HeadPointer.Enabled {
+			headPointer = newHeadPointerMode(pointer, *config.HeadPointer.Gain, *config.HeadPointer.DeadZone)
 		}
 	}
 
-	eventTimeoutDuration := 0 * time.Millisecond
+	// Reused for packing damaged regions before uploading them
+	uploadBuffer := []byte{}
+
+	latencyEstimator := headtracking.NewLatencyEstimator()
+	lastFrameStart := time.Now()
 
 	for !rl.WindowShouldClose() {
+		frameStart := time.Now()
+		frameTime := frameStart.Sub(lastFrameStart)
+		lastFrameStart = frameStart
+
+		// Orientation the camera is pointed in, which anchored displays move along with
+		viewOrientation := headtracking.IdentityQuaternion
+
 		if !displayMetadata.DeviceQuirks.UsesMouseMovement {
 			if hasSensorInitDelayQuirk {
 				if time.Since(sensorInitStartTime) > time.Duration(displayMetadata.DeviceQuirks.SensorInitDelay)*time.Second {
 					log.Info("Movement is now enabled.")
 					hasSensorInitDelayQuirk = false
+
+					hudOverlay.setStatus("")
+					hudOverlay.toast(toastInfo, "Movement is now enabled")
+
+					// Whatever the sensors reported until now isn't trustworthy
+					tracker.Recenter()
+				} else {
+					remaining := time.Duration(displayMetadata.DeviceQuirks.SensorInitDelay)*time.Second - time.Since(sensorInitStartTime)
+					hudOverlay.setStatus(fmt.Sprintf("Movement starts in %d s", int(math.Ceil(remaining.Seconds()))))
 				}
 			} else {
-				lookVector.X = (currentYaw - previousYaw) * 6.5
-				lookVector.Y = -(currentPitch - previousPitch) * 6.5
-
-				if !hasZVectorDisabledQuirk {
-					lookVector.Z = (currentRoll - previousRoll) * 6.5
+				if recenterKey != 0 && rl.IsKeyPressed(recenterKey) {
+					log.Debug("Recentering (key)")
+					tracker.Recenter()
+					hudOverlay.toast(toastInfo, "Recentered")
 				}
 
-				// evil look hacks to not randomly break shit
-				maxTrustedSize := float64(7)
+				// Turning away while moving the pointer doesn't count, as the view is held still
+				isPointerHeld := headPointer != nil && headPointer.isEngaged
 
-				if math.Abs(float64(lookVector.X)) > maxTrustedSize {
-					log.Errorf("WOAH. Ignoring extreme camera movement for vector X: %.02f", lookVector.X)
-					lookVector.X = 0
+				if orientation, ok := tracker.Orientation(); ok && autoRecenter != nil && !isPointerHeld && autoRecenter.Update(orientation, time.Now()) {
+					log.Info("You've been facing away from the displays for a while, recentering")
+					tracker.Recenter()
+					hudOverlay.toast(toastInfo, "Recentered")
 				}
 
-				if math.Abs(float64(lookVector.Y)) > maxTrustedSize {
-					log.Errorf("WOAH. Ignoring extreme camera movement for vector Y: %.02f", lookVector.Y)
-					lookVector.Y = 0
+				predictionInterval := time.Duration(0)
+
+				switch *config.HeadTracking.Prediction {
+				case libconfig.PredictionAuto:
+					predictionInterval = latencyEstimator.Estimate()
+				case libconfig.PredictionFixed:
+					predictionInterval = time.Duration(*config.HeadTracking.PredictionInterval * float32(time.Millisecond))
 				}
 
-				if !hasZVectorDisabledQuirk && math.Abs(float64(lookVector.Z)) > maxTrustedSize {
-					log.Errorf("WOAH. Ignoring extreme camera movement for vector Z: %.02f", lookVector.Z)
-					lookVector.Z = 0
+				isClutchPressed := clutchKey != 0 && rl.IsKeyPressed(clutchKey)
+
+				select {
+				case <-clutchPresses:
+					isClutchPressed = true
+				default:
 				}
 
-				rl.UpdateCameraPro(&camera, movementVector, lookVector, 0)
+				if orientation, ok := tracker.PredictedOrientation(predictionInterval); ok {
+					viewOrientation = orientation
+
+					// Without roll, the camera is kept level
+					if hasZVectorDisabledQuirk {
+						viewOrientation = orientation.WithoutRoll()
+					}
+
+					if headPointer != nil && isClutchPressed {
+						if headPointer.isEngaged {
+							log.Debug("Moving the view with the head again")
+							headPointer.disengage()
+							hudOverlay.toast(toastInfo, "Head moves the view")
+
+							// The view carries on from where it was held, instead of jumping to where the head turned meanwhile
+							tracker.Rebase(headPointer.heldOrientation)
+							viewOrientation = headPointer.heldOrientation
+						} else {
+							log.Debug("Moving the pointer with the head")
+							headPointer.engage(viewOrientation)
+							hudOverlay.toast(toastInfo, "Head moves the pointer")
+							warper.warpTo(focus.focused, frameStart)
+						}
+					}
+
+					if headPointer != nil && headPointer.isEngaged {
+						headPointer.follow(orientation)
+						viewOrientation = headPointer.heldOrientation
+					}
+
+					updateCameraFromOrientation(&camera, viewOrientation)
+				}
 			}
 		} else {
 			rl.UpdateCamera(&camera, rl.CameraFirstPerson)
 		}
 
-		rl.BeginDrawing()
-		rl.ClearBackground(rl.Black)
-		rl.BeginMode3D(camera)
+		if editKey != 0 && rl.IsKeyPressed(editKey) {
+			if editor.isActive {
+				editor.close()
+
+				if err := editor.save(config, configDir, rects); errors.Is(err, libconfig.ErrConfigNotWritable) {
+					log.Warn("Closed the layout editor. The layout can't be saved while running as root, so your changes are only kept until UnrealXR exits")
+					hudOverlay.toast(toastWarning, "Layout can't be saved while running as root")
+				} else if err != nil {
+					log.Errorf("Failed to save layout: %s", err.Error())
+					hudOverlay.toast(toastError, "Failed to save layout")
+				} else {
+					log.Infof("Closed the layout editor and saved the layout to profile '%s'", *config.Profile)
+					hudOverlay.toast(toastInfo, "Saved layout")
+				}
+			} else {
+				// Displays are edited where they are in the layout
+				zoom.reset()
+				editor.open()
+				hudOverlay.toast(toastInfo, "Editing layout")
+				log.Info("Opened the layout editor. Look at a display and press space to grab it, then turn your head to move it. Arrow keys move it, shift and arrow keys rotate it, page up and down push it farther or nearer, plus and minus resize it, and backspace resets it.")
+			}
+		}
 
-		for rectPos, rect := range rects {
-			card := evdiCards[rectPos]
+		gazeDirection := rl.Vector3Normalize(rl.Vector3Subtract(camera.Target, camera.Position))
 
-			ready, err := card.EvdiNode.WaitUntilEventsAreReadyToHandle(eventTimeoutDuration)
+		if !editor.isActive && focus.focused != -1 {
+			isZoomed := true
 
-			if err != nil {
-				log.Errorf("Failed to wait for display events: %s", err.Error())
-				continue
+			if zoomInKey != 0 && rl.IsKeyPressed(zoomInKey) {
+				zoom.zoomBy(focus.focused, 1)
+			} else if zoomOutKey != 0 && rl.IsKeyPressed(zoomOutKey) {
+				zoom.zoomBy(focus.focused, -1)
+			} else if pixelPerfectKey != 0 && rl.IsKeyPressed(pixelPerfectKey) {
+				zoom.togglePixelPerfect(focus.focused, pixelPerfectMagnification(rects[focus.focused], verticalSize, displayMetadata.MaxHeight, fovY))
+			} else {
+				isZoomed = false
 			}
 
-			if ready {
-				if err := card.EvdiNode.HandleEvents(card.EventContext); err != nil {
-					log.Errorf("Failed to handle display events: %s", err.Error())
-					continue
+			if isZoomed {
+				hudOverlay.toast(toastInfo, "Display #%d: %.2fx", focus.focused, zoom.targets[focus.focused])
+			}
+		}
+
+		zoom.update(rects, frameTime)
+		editor.update(rects, camera.Position, gazeDirection, viewOrientation, frameTime, verticalSize)
+		hudOverlay.update(viewOrientation, frameTime, frameStart)
+
+		// Whether anything but the background is drawn where the displays are
+		anyDisplayVisible := false
+
+		for rectPos, rect := range rects {
+			// Capturing happens on its own goroutine, so we only upload whatever frame is newest
+			if frame, damage := evdiCards[rectPos].capture.acquireLatestFrame(); frame != nil {
+				// The display's mode changed, so the texture and plane need to match the new resolution
+				if frame.buffer.Width != rect.Width || frame.buffer.Height != rect.Height {
+					log.Debugf("display #%d: resizing to %dx%d", rectPos, frame.buffer.Width, frame.buffer.Height)
+
+					// UnloadModel doesn't unload material textures, so the old texture has to go separately
+					rl.UnloadTexture(rect.Texture)
+					rl.UnloadModel(rect.Model)
+
+					rect.Texture, rect.Model = loadDisplayModel(frame.buffer.Width, frame.buffer.Height, verticalSize, rect.CurveRadius, quality)
+					rect.Width, rect.Height = frame.buffer.Width, frame.buffer.Height
+				}
+
+				uploadDamage(rect.Texture, frame, damage, &uploadBuffer)
+				renderStatistics.mipmapTime += quality.refresh(&rect.Texture)
+				evdiCards[rectPos].capture.releaseFrame(frame)
+				uploadStatistics[rectPos].recordFrame(damage, rect.Width, rect.Height)
+			}
+
+			rect.AnchorRotation = rect.Anchor.Update(viewOrientation, frameTime)
+
+			position := rect.Pose.Position.Scale(1 / rect.Magnification)
+
+			rect.WorldPos = rl.Vector3Add(viewerPosition, toRaylibVector(rect.AnchorRotation.Rotate(position)))
+			rect.Rotation = rect.AnchorRotation.Multiply(rect.Pose.Rotation).Multiply(uprightRotation)
+			rect.Model.Transform = matrixFromRotation(rect.Rotation, rect.Pose.Scale)
+
+			if failure := evdiCards[rectPos].capture.failed(); (failure != nil) != rect.HasFailed {
+				rect.HasFailed = failure != nil
+
+				if rect.HasFailed {
+					log.Errorf("display #%d: %s", rectPos, failure.Error())
+					hudOverlay.toast(toastError, "Display #%d failed to change its resolution", rectPos)
 				}
+			}
+
+			rect.IsDisplayOn = !rect.HasFailed && evdiCards[rectPos].capture.isDisplayOn()
+
+			if rect.IsDisplayOn || rect.HasFailed || *config.DisplayConfig.BlankedBehavior != libconfig.BlankedBehaviorHidden {
+				anyDisplayVisible = true
+			}
+
+			if rect.IsDisplayOn {
+
+				cursors[rectPos] = evdiCards[rectPos].capture.currentCursor()
+				cursorOverlays[rectPos].update(cursors[rectPos], rect.Width, rect.Height, verticalSize)
+			}
+		}
+
+		focus.update(displayUnderGaze(rects, camera.Position, gazeDirection, verticalSize), frameStart)
+
+		if warper != nil {
+			warper.update(rects, cursors, frameStart)
+		}
+
+		if headPointer != nil {
+			headPointer.update(rects, cursors, warper)
+		}
+
+		// Draws the background and the displays as seen from the current camera. In stereo mode, this happens once per eye
+		drawDisplays := func() {
+			background.draw(viewerPosition)
+
+			for rectPos, rect := range rects {
+				tint := rl.White
+
+				if rect.HasFailed {
+					rl.SetMaterialTexture(rect.Model.Materials, rl.MapAlbedo, placeholderTexture)
+				} else if !rect.IsDisplayOn {
+					switch *config.DisplayConfig.BlankedBehavior {
+					case libconfig.BlankedBehaviorHidden:
+						continue
 
-				card.EvdiNode.GrabPixels(card.Rect)
+					case libconfig.BlankedBehaviorDimmed:
+						tint = dimmedDisplayTint
 
-				pixels := unsafe.Slice(
-					(*color.RGBA)(unsafe.Pointer(&card.Buffer.Buffer[0])),
-					len(card.Buffer.Buffer)/4,
+					case libconfig.BlankedBehaviorPlaceholder:
+						rl.SetMaterialTexture(rect.Model.Materials, rl.MapAlbedo, placeholderTexture)
+					}
+				}
+
+				rl.DrawModelEx(
+					rect.Model,
+					rect.WorldPos,
+					// rotate around X to make it vertical
+					rl.Vector3{
+						X: 0,
+						Y: 0,
+						Z: 0,
+					},
+					0,
+					rl.Vector3{
+						X: 1,
+						Y: 1,
+						Z: 1,
+					},
+					tint,
 				)
 
-				rl.UpdateTexture(rect.Texture, pixels)
-				card.EvdiNode.RequestUpdate(card.Buffer)
+				if !rect.IsDisplayOn {
+					// Swap the display's own texture back in for when the display turns on again
+					rl.SetMaterialTexture(rect.Model.Materials, rl.MapAlbedo, rect.Texture)
+					continue
+				}
+
+				cursorOverlays[rectPos].draw(cursors[rectPos], rect.Rotation, rect.Pose.Scale, rect.WorldPos, verticalSize, rect.CurveRadius)
 			}
 
-			worldPos := rl.Vector3{
-				X: 0,
-				Y: verticalSize / 2,
-				Z: 0,
+			// The layout editor outlines displays on its own
+			if *config.Focus.Highlight && !editor.isActive && focus.focused != -1 && rects[focus.focused].IsDisplayOn {
+				drawDisplayOutline(rects[focus.focused], verticalSize, focusHighlightMargin, focusHighlightColor)
 			}
 
-			if *config.DisplayConfig.UseCircularSpacing == true {
-				yawRad := float32(rl.Deg2rad * rect.CurrentAngle)
+			editor.draw(rects, viewerPosition, verticalSize)
+		}
 
-				// WTF?
-				posX := float32(math.Sin(float64(yawRad))) * radius
-				posZ := -float32(math.Cos(float64(yawRad))) * radius
+		drawScene := func() {
+			start := time.Now()
+			drawDisplays()
+			renderStatistics.sceneTime += time.Since(start)
+		}
 
-				worldPos.X = posX
-				worldPos.Z = posZ + radius
-			} else {
-				worldPos.X = rect.CurrentDisplaySpacing
-			}
+		if stereo != nil {
+			stereo.render(camera, background.clearColor(), drawScene)
+		} else if supersampling != nil {
+			supersampling.render(camera, background.clearColor(), drawScene)
+		}
 
-			rl.DrawModelEx(
-				rect.Model,
-				worldPos,
-				// rotate around X to make it vertical
-				rl.Vector3{
-					X: 0,
-					Y: 0,
-					Z: 0,
-				},
-				0,
-				rl.Vector3{
-					X: 1,
-					Y: 1,
-					Z: 1,
-				},
-				rl.White,
-			)
+		rl.BeginDrawing()
+		rl.ClearBackground(background.clearColor())
+
+		if stereo != nil {
+			stereo.draw()
+		} else if supersampling != nil {
+			// Stereo blits happen with or without supersampling, so only this one counts as downsampling
+			downsampleStart := time.Now()
+			supersampling.draw()
+			renderStatistics.downsampleTime += time.Since(downsampleStart)
+		} else {
+			rl.BeginMode3D(camera)
+			drawScene()
+			rl.EndMode3D()
+		}
+
+		// The HUD isn't supersampled, so its text stays crisp
+		if stereo != nil {
+			for eye := range int32(2) {
+				hudOverlay.draw(eye*stereo.width, 0, stereo.width, stereo.height, frameStart)
+			}
+		} else {
+			hudOverlay.draw(0, 0, int32(displayMetadata.MaxWidth), int32(displayMetadata.MaxHeight), frameStart)
 		}
 
-		rl.EndMode3D()
 		rl.EndDrawing()
+		renderStatistics.recordFrame()
+
+		// Drawing ends with waiting for the buffer swap, so this is about when the frame starts being shown
+		latencyEstimator.Record(time.Since(frameStart), frameTime)
+
+		// Nothing on screen changes while every display is hidden in front of a background that looks the same in every direction (and the layout editor
+		// is closed), so there's no need to render at full speed then. Otherwise, the view still follows the head, so only the uploads stop as there are no new frames
+		canIdle := !anyDisplayVisible && background.isStatic() && !editor.isActive
+
+		if canIdle != isIdle {
+			isIdle = canIdle
+
+			if isIdle {
+				log.Debugf("Every display is hidden in front of a static background, dropping to %d FPS", *config.DisplayConfig.IdleFPS)
+				rl.SetTargetFPS(int32(*config.DisplayConfig.IdleFPS))
+			} else {
+				rl.SetTargetFPS(int32(displayMetadata.MaxRefreshRate))
+			}
+		}
 	}
 
 	log.Info("Goodbye!")
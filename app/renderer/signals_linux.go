@@ -0,0 +1,15 @@
+//go:build linux
+// +build linux
+
+package renderer
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Sends SIGUSR1 to the channel, which recenters the displays
+func notifyOnRecenterSignal(signals chan<- os.Signal) {
+	signal.Notify(signals, syscall.SIGUSR1)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package renderer
+
+import (
+	"os"
+)
+
+// Recentering through signals is only supported on Linux
+func notifyOnRecenterSignal(signals chan<- os.Signal) {}
@@ -0,0 +1,96 @@
+package renderer
+
+import (
+	"fmt"
+	"image/color"
+
+	libconfig "git.lunr.sh/UnrealXR/unrealxr/app/config"
+	"git.lunr.sh/UnrealXR/unrealxr/app/edidtools"
+	arcommons "git.lunr.sh/UnrealXR/unrealxr/ardriver/commons"
+	"github.com/charmbracelet/log"
+	"github.com/tebeka/atexit"
+
+	rl "git.lunr.sh/UnrealXR/raylib-go/raylib"
+)
+
+// Renders a view for each eye side by side, for glasses in side-by-side 3D mode
+type stereoRenderer struct {
+	// Left and right eye
+	eyeTargets [2]rl.RenderTexture2D
+	width      int32
+	height     int32
+
+	// Distance between the eyes, in world units
+	eyeSeparation float32
+	// Distance at which the eye views converge, in world units
+	convergenceDistance float32
+}
+
+// Switches the glasses into side-by-side mode and sets up rendering for it, with each eye supersampled by a factor. The glasses are switched back to 2D on exit
+func enableStereo(headset arcommons.ARDevice, stereoConfig libconfig.StereoConfig, displayMetadata *edidtools.DisplayMetadata, supersampling float32) (*stereoRenderer, error) {
+	if err := headset.SetStereoMode(true, displayMetadata.MaxRefreshRate); err != nil {
+		return nil, fmt.Errorf("failed to switch glasses to side-by-side mode: %w", err)
+	}
+
+	atexit.Register(func() {
+		if err := headset.SetStereoMode(false, displayMetadata.MaxRefreshRate); err != nil {
+			log.Errorf("Failed to switch glasses back to 2D mode: %s", err.Error())
+		}
+	})
+
+	// Each eye gets the full resolution of the glasses
+	rl.SetWindowSize(2*displayMetadata.MaxWidth, displayMetadata.MaxHeight)
+
+	// The displays are sized to fill the FOV at cameraDistance, so that's where they should appear to be
+	unitsPerMeter := cameraDistance / *stereoConfig.DisplayDistance
+
+	stereo := &stereoRenderer{
+		width:               int32(displayMetadata.MaxWidth),
+		height:              int32(displayMetadata.MaxHeight),
+		eyeSeparation:       *stereoConfig.IPD / 1000 * unitsPerMeter,
+		convergenceDistance: *stereoConfig.ConvergenceDistance * unitsPerMeter,
+	}
+
+	for eye := range stereo.eyeTargets {
+		stereo.eyeTargets[eye] = loadSupersampledTarget(displayMetadata.MaxWidth, displayMetadata.MaxHeight, supersampling)
+	}
+
+	log.Infof("Rendering in stereo with an eye separation of %.03f units, converging at %.02f units", stereo.eyeSeparation, stereo.convergenceDistance)
+
+	return stereo, nil
+}
+
+// Gets the camera of an eye, which is offset sideways from the head camera and turned in to converge with the other eye
+func (stereo *stereoRenderer) eyeCamera(camera rl.Camera3D, offset float32) rl.Camera3D {
+	forward := rl.Vector3Normalize(rl.Vector3Subtract(camera.Target, camera.Position))
+	right := rl.Vector3Normalize(rl.Vector3CrossProduct(forward, camera.Up))
+
+	eyeCamera := camera
+	eyeCamera.Position = rl.Vector3Add(camera.Position, rl.Vector3Scale(right, offset))
+	eyeCamera.Target = rl.Vector3Add(camera.Position, rl.Vector3Scale(forward, stereo.convergenceDistance))
+
+	return eyeCamera
+}
+
+// Renders the view of both eyes on a background color. Must be called before BeginDrawing
+func (stereo *stereoRenderer) render(camera rl.Camera3D, clearColor color.RGBA, drawScene func()) {
+	eyeOffsets := [2]float32{-stereo.eyeSeparation / 2, stereo.eyeSeparation / 2}
+
+	for eye, target := range stereo.eyeTargets {
+		rl.BeginTextureMode(target)
+		rl.ClearBackground(clearColor)
+		rl.BeginMode3D(stereo.eyeCamera(camera, eyeOffsets[eye]))
+
+		drawScene()
+
+		rl.EndMode3D()
+		rl.EndTextureMode()
+	}
+}
+
+// Draws the views of both eyes side by side
+func (stereo *stereoRenderer) draw() {
+	for eye, target := range stereo.eyeTargets {
+		drawRenderTarget(target, int32(eye)*stereo.width, 0, stereo.width, stereo.height)
+	}
+}
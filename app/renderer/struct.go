@@ -6,7 +6,14 @@ import (
 
 type EvdiDisplayMetadata struct {
 	EvdiNode     *libevdi.EvdiNode
-	Rect         *libevdi.EvdiDisplayRect
-	Buffer       *libevdi.EvdiBuffer
 	EventContext *libevdi.EvdiEventContext
+
+	capture *displayCapture
+}
+
+// Stops capturing frames from the display, if the renderer started doing so. Must be called before disconnecting the EVDI node
+func (card *EvdiDisplayMetadata) StopCapture() {
+	if card.capture != nil {
+		card.capture.stopCapture()
+	}
 }
@@ -0,0 +1,74 @@
+package renderer
+
+import (
+	"image/color"
+
+	rl "git.lunr.sh/UnrealXR/raylib-go/raylib"
+)
+
+// Renders the scene at a higher resolution than the glasses, then scales it down, which smooths out edges and fine detail
+type supersampler struct {
+	target rl.RenderTexture2D
+	// Size the scene is scaled down to
+	width  int32
+	height int32
+}
+
+func newSupersampler(width, height int, factor float32) *supersampler {
+	return &supersampler{
+		target: loadSupersampledTarget(width, height, factor),
+		width:  int32(width),
+		height: int32(height),
+	}
+}
+
+// Creates a render target that is scaled up by a factor. Scaling it back down by up to 2 with bilinear filtering averages every rendered pixel
+func loadSupersampledTarget(width, height int, factor float32) rl.RenderTexture2D {
+	target := rl.LoadRenderTexture(int32(float32(width)*factor), int32(float32(height)*factor))
+
+	if factor > 1 {
+		rl.SetTextureFilter(target.Texture, rl.FilterBilinear)
+	}
+
+	return target
+}
+
+// Draws a render target at a position and size on the screen
+func drawRenderTarget(target rl.RenderTexture2D, x, y, width, height int32) {
+	// Render textures are stored upside down
+	rl.DrawTexturePro(
+		target.Texture,
+		rl.Rectangle{
+			X:      0,
+			Y:      0,
+			Width:  float32(target.Texture.Width),
+			Height: -float32(target.Texture.Height),
+		},
+		rl.Rectangle{
+			X:      float32(x),
+			Y:      float32(y),
+			Width:  float32(width),
+			Height: float32(height),
+		},
+		rl.Vector2{},
+		0,
+		rl.White,
+	)
+}
+
+// Renders the scene as seen from a camera on a background color. Must be called before BeginDrawing
+func (supersampler *supersampler) render(camera rl.Camera3D, clearColor color.RGBA, drawScene func()) {
+	rl.BeginTextureMode(supersampler.target)
+	rl.ClearBackground(clearColor)
+	rl.BeginMode3D(camera)
+
+	drawScene()
+
+	rl.EndMode3D()
+	rl.EndTextureMode()
+}
+
+// Draws the scene scaled down to the glasses
+func (supersampler *supersampler) draw() {
+	drawRenderTarget(supersampler.target, 0, 0, supersampler.width, supersampler.height)
+}
@@ -0,0 +1,33 @@
+package renderer
+
+import (
+	"math"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/headtracking"
+
+	rl "git.lunr.sh/UnrealXR/raylib-go/raylib"
+)
+
+// Stands the planes raylib generates (lying flat, facing up) upright, facing the viewer. The top of the texture ends up at the top
+var uprightRotation = headtracking.QuaternionFromAxisAngle(headtracking.Vector3{X: 1, Y: 0, Z: 0}, math.Pi/2)
+
+// Creates a model transform that scales and then rotates
+func matrixFromRotation(rotation headtracking.Quaternion, scale float32) rl.Matrix {
+	x, y, z, w := rotation.X, rotation.Y, rotation.Z, rotation.W
+
+	// Column major, as raylib hands matrices to OpenGL
+	return rl.Matrix{
+		M0: scale * (1 - 2*(y*y+z*z)), M4: scale * 2 * (x*y - z*w), M8: scale * 2 * (x*z + y*w), M12: 0,
+		M1: scale * 2 * (x*y + z*w), M5: scale * (1 - 2*(x*x+z*z)), M9: scale * 2 * (y*z - x*w), M13: 0,
+		M2: scale * 2 * (x*z - y*w), M6: scale * 2 * (y*z + x*w), M10: scale * (1 - 2*(x*x+y*y)), M14: 0,
+		M3: 0, M7: 0, M11: 0, M15: 1,
+	}
+}
+
+func toRaylibVector(vector headtracking.Vector3) rl.Vector3 {
+	return rl.Vector3{
+		X: vector.X,
+		Y: vector.Y,
+		Z: vector.Z,
+	}
+}
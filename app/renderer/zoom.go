@@ -0,0 +1,101 @@
+package renderer
+
+import (
+	"math"
+	"time"
+
+	libconfig "git.lunr.sh/UnrealXR/unrealxr/app/config"
+)
+
+// How close a zooming display needs to get to its magnification before it stops animating, relative to the magnification
+const zoomSettleRatio = 0.001
+
+// Magnifies displays by moving them towards the viewer. Every display has its own zoom, which is applied on top of its pose
+type zoomController struct {
+	// Magnification each display is zooming to
+	targets []float32
+	// Magnification each display had before it was zoomed to pixel-perfect, or 0 if it isn't
+	beforePixelPerfect []float32
+
+	step             float32
+	minMagnification float32
+	maxMagnification float32
+	// Time constant of the zoom animation. 0 zooms instantly
+	easing time.Duration
+}
+
+func newZoomController(displayCount int, zoomConfig libconfig.ZoomConfig) *zoomController {
+	zoom := &zoomController{
+		targets:            make([]float32, displayCount),
+		beforePixelPerfect: make([]float32, displayCount),
+		step:               *zoomConfig.Step,
+		minMagnification:   1 / *zoomConfig.MaxMagnification,
+		maxMagnification:   *zoomConfig.MaxMagnification,
+		easing:             time.Duration(*zoomConfig.Easing * float32(time.Second)),
+	}
+
+	zoom.reset()
+
+	return zoom
+}
+
+// Zooms a display in by a step, or out if steps is negative
+func (zoom *zoomController) zoomBy(display int, steps float32) {
+	target := zoom.targets[display] * float32(math.Pow(float64(zoom.step), float64(steps)))
+
+	zoom.targets[display] = max(zoom.minMagnification, min(zoom.maxMagnification, target))
+	zoom.beforePixelPerfect[display] = 0
+}
+
+// Zooms a display to the magnification at which it's pixel-perfect, or back to where it was if it already is
+func (zoom *zoomController) togglePixelPerfect(display int, magnification float32) {
+	if before := zoom.beforePixelPerfect[display]; before != 0 {
+		zoom.targets[display] = before
+		zoom.beforePixelPerfect[display] = 0
+
+		return
+	}
+
+	zoom.beforePixelPerfect[display] = zoom.targets[display]
+	zoom.targets[display] = magnification
+}
+
+// Zooms every display back to its pose
+func (zoom *zoomController) reset() {
+	for display := range zoom.targets {
+		zoom.targets[display] = 1
+		zoom.beforePixelPerfect[display] = 0
+	}
+}
+
+// Animates the magnification of the displays towards what they're zooming to
+func (zoom *zoomController) update(rects []*TextureModelPair, elapsed time.Duration) {
+	for display, rect := range rects {
+		target := zoom.targets[display]
+
+		if zoom.easing <= 0 || math.Abs(float64(rect.Magnification-target)) < float64(target*zoomSettleRatio) {
+			rect.Magnification = target
+			continue
+		}
+
+		// Same easing as following layouts, so zooming speed doesn't depend on the frame rate
+		t := 1 - float32(math.Exp(-float64(elapsed)/float64(zoom.easing)))
+		rect.Magnification += (target - rect.Magnification) * t
+	}
+}
+
+// Gets the magnification at which one pixel of a display covers one pixel of the glasses, where it's looked at straight on.
+// The vertical size is the display's height at scale 1, and the glasses' height and vertical FOV (in degrees) are those of a single eye
+func pixelPerfectMagnification(rect *TextureModelPair, verticalSize float32, glassesHeight int, fovY float32) float32 {
+	distance := rect.Pose.Position.Length()
+	displayHeight := verticalSize * rect.Pose.Scale
+
+	// At this distance, the display covers as many pixels of the glasses vertically as it has
+	perfectDistance := displayHeight * float32(glassesHeight) / (2 * float32(math.Tan(float64(fovY)*math.Pi/360)) * float32(rect.Height))
+
+	if distance == 0 || perfectDistance == 0 {
+		return 1
+	}
+
+	return distance / perfectDistance
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"slices"
+	"strings"
+
+	"git.lunr.sh/UnrealXR/unrealxr/app/edidtools"
+	"git.lunr.sh/UnrealXR/unrealxr/app/platformtools"
+	"git.lunr.sh/UnrealXR/unrealxr/app/privhelper"
+	"github.com/charmbracelet/log"
+	"github.com/urfave/cli/v3"
+)
+
+// Checks if the current user is a member of the given group
+func isInGroup(groupName string) (bool, error) {
+	currentUser, err := user.Current()
+
+	if err != nil {
+		return false, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	group, err := user.LookupGroup(groupName)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to look up group '%s': %w", groupName, err)
+	}
+
+	groupIDs, err := currentUser.GroupIds()
+
+	if err != nil {
+		return false, fmt.Errorf("failed to get groups of current user: %w", err)
+	}
+
+	return slices.Contains(groupIDs, group.Gid), nil
+}
+
+func setupEntrypoint(_ context.Context, cmd *cli.Command) error {
+	group := cmd.String("group")
+	rules := privhelper.GenerateUdevRules(group)
+
+	if cmd.Bool("print") {
+		fmt.Print(rules)
+		return nil
+	}
+
+	config, _, err := loadConfig()
+
+	if err != nil {
+		return err
+	}
+
+	rulesPath := cmd.String("rules-path")
+	log.Infof("Installing udev rules to '%s'", rulesPath)
+
+	err = platformtools.RunElevated(
+		escalationOptions(config),
+		strings.NewReader(rules),
+		"/bin/sh", "-c", `install -m 0644 /dev/stdin "$0" && udevadm control --reload-rules && udevadm trigger && udevadm settle`, rulesPath,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to install udev rules: %w", err)
+	}
+
+	log.Info("Installed udev rules")
+
+	if inGroup, err := isInGroup(group); err != nil {
+		log.Warnf("Could not check group membership: %s", err.Error())
+	} else if !inGroup {
+		log.Warnf("You're not in the '%s' group. Devices are still accessible while you're logged in locally, but for full access run:", group)
+		log.Warnf("    sudo usermod -aG %s $USER", group)
+		log.Warn("Then log out and back in.")
+	}
+
+	card, connector := "", ""
+	displayMetadata, err := edidtools.FetchXRGlassEDID(*config.Overrides.AllowUnsupportedDevices)
+
+	if err != nil {
+		log.Warnf("Could not find your XR device, so its EDID override can't be checked: %s", err.Error())
+	} else {
+		card, connector = displayMetadata.LinuxDRMCard, displayMetadata.LinuxDRMConnector
+	}
+
+	problems := privhelper.CheckAccess(card, connector)
+
+	if len(problems) == 0 {
+		log.Info("Everything is accessible. UnrealXR will run without privilege escalation")
+		return nil
+	}
+
+	log.Info("The following still needs root. UnrealXR will use the privileged helper for these:")
+
+	for _, problem := range problems {
+		if problem.FixableWithUdev {
+			log.Infof("    %s (%s) -- try replugging the device or rebooting", problem.Path, problem.Description)
+		} else {
+			log.Infof("    %s (%s)", problem.Path, problem.Description)
+		}
+	}
+
+	return nil
+}
+
+var setupCommand = &cli.Command{
+	Name:  "setup",
+	Usage: "Install udev rules so UnrealXR needs root for as little as possible",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "group",
+			Usage: "group that gets access to XR devices and EVDI",
+			Value: "video",
+		},
+		&cli.StringFlag{
+			Name:  "rules-path",
+			Usage: "path to install the udev rules to",
+			Value: privhelper.UdevRulesPath,
+		},
+		&cli.BoolFlag{
+			Name:  "print",
+			Usage: "only print the udev rules instead of installing them",
+		},
+	},
+	Action: setupEntrypoint,
+}
@@ -0,0 +1,166 @@
+//go:build linux
+// +build linux
+
+package uinput
+
+import (
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioctls of /dev/uinput, from linux/uinput.h
+const (
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiDevSetup   = 0x405c5503
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetRelBit  = 0x40045566
+)
+
+// Event types and codes, from linux/input-event-codes.h
+const (
+	evSyn = 0x00
+	evKey = 0x01
+	evRel = 0x02
+
+	synReport = 0x00
+
+	relX = 0x00
+	relY = 0x01
+
+	btnLeft   = 0x110
+	btnRight  = 0x111
+	btnMiddle = 0x112
+
+	busVirtual = 0x06
+)
+
+type inputID struct {
+	busType uint16
+	vendor  uint16
+	product uint16
+	version uint16
+}
+
+// struct uinput_setup
+type uinputSetup struct {
+	id           inputID
+	name         [80]byte
+	ffEffectsMax uint32
+}
+
+// struct input_event
+type inputEvent struct {
+	time      unix.Timeval
+	eventType uint16
+	code      uint16
+	value     int32
+}
+
+// Virtual mouse, which moves the pointer with relative motion just like a real one.
+type Pointer struct {
+	file *os.File
+}
+
+func ioctlPointer(file *os.File, request uintptr, argument unsafe.Pointer) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, file.Fd(), request, uintptr(argument)); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// Creates a virtual mouse on an opened uinput node. Once this is done, the node can only be used to send pointer events, so it's safe to hand out
+// (see privhelper.Operations.CreateVirtualPointer).
+func CreatePointerDevice(file *os.File, name string) error {
+	// Compositors only treat devices with buttons as mice, so we need them even if we never press them
+	for _, bit := range []struct {
+		request uintptr
+		value   int
+	}{
+		{uiSetEvBit, evKey},
+		{uiSetEvBit, evRel},
+		{uiSetKeyBit, btnLeft},
+		{uiSetKeyBit, btnRight},
+		{uiSetKeyBit, btnMiddle},
+		{uiSetRelBit, relX},
+		{uiSetRelBit, relY},
+	} {
+		if err := unix.IoctlSetInt(int(file.Fd()), uint(bit.request), bit.value); err != nil {
+			return fmt.Errorf("failed to set up virtual pointer: %w", err)
+		}
+	}
+
+	setup := uinputSetup{
+		id: inputID{
+			busType: busVirtual,
+		},
+	}
+
+	copy(setup.name[:len(setup.name)-1], name)
+
+	if err := ioctlPointer(file, uiDevSetup, unsafe.Pointer(&setup)); err != nil {
+		return fmt.Errorf("failed to set up virtual pointer: %w", err)
+	}
+
+	if err := ioctlPointer(file, uiDevCreate, nil); err != nil {
+		return fmt.Errorf("failed to create virtual pointer: %w", err)
+	}
+
+	return nil
+}
+
+// Wraps a uinput node that a virtual mouse was already created on (see CreatePointerDevice). The pointer owns the file from now on.
+func NewPointer(file *os.File) *Pointer {
+	return &Pointer{
+		file: file,
+	}
+}
+
+// Writes events, followed by a report that makes them take effect together
+func (pointer *Pointer) write(events ...inputEvent) error {
+	events = append(events, inputEvent{eventType: evSyn, code: synReport})
+	now := unix.NsecToTimeval(time.Now().UnixNano())
+
+	for i := range events {
+		events[i].time = now
+	}
+
+	buffer := unsafe.Slice((*byte)(unsafe.Pointer(&events[0])), len(events)*int(unsafe.Sizeof(events[0])))
+
+	if _, err := pointer.file.Write(buffer); err != nil {
+		return fmt.Errorf("failed to send pointer events: %w", err)
+	}
+
+	return nil
+}
+
+// Moves the pointer by a distance, before the compositor applies pointer acceleration.
+func (pointer *Pointer) Move(dx, dy int32) error {
+	events := []inputEvent{}
+
+	if dx != 0 {
+		events = append(events, inputEvent{eventType: evRel, code: relX, value: dx})
+	}
+
+	if dy != 0 {
+		events = append(events, inputEvent{eventType: evRel, code: relY, value: dy})
+	}
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	return pointer.write(events...)
+}
+
+// Removes the virtual mouse.
+func (pointer *Pointer) Close() error {
+	ioctlPointer(pointer.file, uiDevDestroy, nil)
+	return pointer.file.Close()
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+// +build !linux
+
+package uinput
+
+import (
+	"fmt"
+	"os"
+)
+
+// Virtual mouse, which moves the pointer with relative motion just like a real one.
+type Pointer struct{}
+
+func CreatePointerDevice(file *os.File, name string) error {
+	return fmt.Errorf("uinput is only supported on Linux")
+}
+
+func NewPointer(file *os.File) *Pointer {
+	file.Close()
+	return &Pointer{}
+}
+
+func (pointer *Pointer) Move(dx, dy int32) error {
+	return fmt.Errorf("uinput is only supported on Linux")
+}
+
+func (pointer *Pointer) Close() error {
+	return nil
+}
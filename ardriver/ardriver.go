@@ -2,15 +2,18 @@ package ardriver
 
 import (
 	"fmt"
+	"os"
 
 	"git.lunr.sh/UnrealXR/unrealxr/ardriver/commons"
 	"git.lunr.sh/UnrealXR/unrealxr/ardriver/dummy"
 	"git.lunr.sh/UnrealXR/unrealxr/ardriver/xreal"
 )
 
-func GetDevice() (commons.ARDevice, error) {
+// Opens the first enabled AR device. hidRawNodes are already opened hidraw nodes of the device, which drivers use
+// instead of opening the device themselves if possible
+func GetDevice(hidRawNodes []*os.File) (commons.ARDevice, error) {
 	if xreal.IsXrealEnabled {
-		device, err := xreal.New()
+		device, err := xreal.New(hidRawNodes)
 
 		if err != nil {
 			fmt.Printf("failed to initialize xreal device: %w\n", err)
@@ -1,9 +1,64 @@
 package commons
 
+// Orientation of the headset, as a unit quaternion in the NED convention (X is forward, Y is right, Z is down).
+type Quaternion struct {
+	X float32
+	Y float32
+	Z float32
+	W float32
+}
+
+// Physical button on a headset
+type ARButton int
+
+const (
+	ARButtonBrightnessUp ARButton = iota
+	ARButtonBrightnessDown
+	ARButtonVolumeUp
+	ARButtonVolumeDown
+	ARButtonBlendCycle
+	ARButtonControlToggle
+)
+
+// Names of the buttons, as used in config files.
+var ARButtonNames = map[string]ARButton{
+	"brightness_up":   ARButtonBrightnessUp,
+	"brightness_down": ARButtonBrightnessDown,
+	"volume_up":       ARButtonVolumeUp,
+	"volume_down":     ARButtonVolumeDown,
+	"blend_cycle":     ARButtonBlendCycle,
+	"control_toggle":  ARButtonControlToggle,
+}
+
+// Electrochromic dimming of a headset's lenses, which decides how much of the real world shows through
+type ARBlendState int
+
+const (
+	ARBlendStateLow ARBlendState = iota
+	ARBlendStateMedium
+	ARBlendStateFull
+)
+
+// Names of the blend states, as used in config files.
+var ARBlendStateNames = map[string]ARBlendState{
+	"low":    ARBlendStateLow,
+	"medium": ARBlendStateMedium,
+	"full":   ARBlendStateFull,
+}
+
 type AREventListener struct {
 	PitchCallback func(float32)
 	YawCallback   func(float32)
 	RollCallback  func(float32)
+	// Called with the absolute orientation of the headset, along with the sensor's timestamp of the reading in nanoseconds. Timestamps only need to increase monotonically, they don't have to match any clock.
+	// Drivers that support it should prefer this over the Euler angle callbacks.
+	OrientationCallback func(orientation Quaternion, timestamp uint64)
+	// Called when a button on the headset is pressed, if the driver supports it.
+	// This runs on the driver's event goroutine while it holds the device's event lock, so it must not call back into the device (ie. SetStereoMode would
+	// deadlock). Hand anything that needs the device off to another goroutine instead.
+	ButtonCallback func(ARButton)
+	// Called when the blend state of the headset's lenses changes, if the driver supports it. The same restrictions as for ButtonCallback apply.
+	BlendStateCallback func(ARBlendState)
 }
 
 type ARDevice interface {
@@ -19,4 +74,6 @@ type ARDevice interface {
 	IsEventBasedLibrary() bool
 	// Registers event listeners for the AR device.
 	RegisterEventListeners(eventListener *AREventListener)
+	// Switches the AR device's display between mono and side-by-side stereo output at the given refresh rate.
+	SetStereoMode(enabled bool, refreshRate int) error
 }
@@ -35,6 +35,10 @@ func (device *DummyDevice) Poll() error {
 
 func (device *DummyDevice) RegisterEventListeners(*commons.AREventListener) {}
 
+func (device *DummyDevice) SetStereoMode(enabled bool, refreshRate int) error {
+	return nil
+}
+
 func New() (*DummyDevice, error) {
 	return &DummyDevice{}, nil
 }
@@ -37,6 +37,10 @@ func (device *DummyDevice) Poll() error {
 
 func (device *DummyDevice) RegisterEventListeners(*commons.AREventListener) {}
 
+func (device *DummyDevice) SetStereoMode(enabled bool, refreshRate int) error {
+	return fmt.Errorf("dummy device is not enabled")
+}
+
 func New() (*DummyDevice, error) {
 	return nil, fmt.Errorf("dummy device is not enabled")
 }
@@ -0,0 +1,22 @@
+package xreal
+
+// USB vendor ID used by all Xreal devices. Keep in sync with xrealsrc/hid_ids.c.
+const VendorID uint16 = 0x3318
+
+// USB product IDs of all supported Xreal devices. Keep in sync with xrealsrc/hid_ids.c.
+var ProductIDs = map[uint16]string{
+	0x0424: "XREAL Air",
+	0x0428: "XREAL Air 2",
+	0x0432: "XREAL Air 2 Pro",
+	0x0426: "XREAL Air 2 Ultra",
+}
+
+// Checks if a USB vendor and product ID pair belongs to a supported Xreal device.
+func IsSupportedDevice(vendorID, productID uint16) bool {
+	if vendorID != VendorID {
+		return false
+	}
+
+	_, ok := ProductIDs[productID]
+	return ok
+}
@@ -4,6 +4,8 @@
 package xreal
 
 import (
+	"os"
+
 	xreal "git.lunr.sh/UnrealXR/unrealxr/ardriver/xreal/xrealsrc"
 )
 
@@ -13,11 +15,13 @@ type XrealDevice struct {
 	*xreal.XrealDevice
 }
 
-func New() (*XrealDevice, error) {
+// Opens the glasses. If hidRawNodes is empty, the device is looked up and opened directly
+func New(hidRawNodes []*os.File) (*XrealDevice, error) {
 	device := &XrealDevice{
 		XrealDevice: &xreal.XrealDevice{},
 	}
 
+	device.UseHIDRawNodes(hidRawNodes)
 	err := device.Initialize()
 
 	if err != nil {
@@ -5,6 +5,7 @@ package xreal
 
 import (
 	"fmt"
+	"os"
 
 	"git.lunr.sh/UnrealXR/unrealxr/ardriver/commons"
 )
@@ -37,6 +38,10 @@ func (device *XrealDevice) Poll() error {
 
 func (device *XrealDevice) RegisterEventListeners(*commons.AREventListener) {}
 
-func New() (*XrealDevice, error) {
+func (device *XrealDevice) SetStereoMode(enabled bool, refreshRate int) error {
+	return fmt.Errorf("xreal is not enabled")
+}
+
+func New([]*os.File) (*XrealDevice, error) {
 	return nil, fmt.Errorf("xreal is not enabled")
 }
@@ -24,8 +24,26 @@
 
 #include "device.h"
 
+#include <errno.h>
+#include <poll.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <unistd.h>
+
+#ifdef __linux__
+#include <linux/hidraw.h>
+#include <linux/input.h>
+#include <sys/ioctl.h>
+#endif
+
 #include <hidapi/hidapi.h>
 
+struct device_hid_t {
+    hid_device* hid;
+    int fd;
+};
+
 static size_t hid_device_counter = 0;
 
 bool device_init() {
@@ -48,3 +66,124 @@ void device_exit() {
         hid_exit();
     }
 }
+
+device_hid_type* device_hid_open_path(const char* path) {
+    hid_device* hid = hid_open_path(path);
+
+    if (!hid) {
+        return NULL;
+    }
+
+    device_hid_type* device = malloc(sizeof(device_hid_type));
+
+    if (!device) {
+        hid_close(hid);
+        return NULL;
+    }
+
+    device->hid = hid;
+    device->fd = -1;
+    return device;
+}
+
+device_hid_type* device_hid_open_fd(int fd) {
+    const int duplicated_fd = dup(fd);
+
+    if (duplicated_fd < 0) {
+        return NULL;
+    }
+
+    device_hid_type* device = malloc(sizeof(device_hid_type));
+
+    if (!device) {
+        close(duplicated_fd);
+        return NULL;
+    }
+
+    device->hid = NULL;
+    device->fd = duplicated_fd;
+    return device;
+}
+
+bool device_hid_fd_info(int fd, uint16_t* vendor_id, uint16_t* product_id, int* interface_number) {
+#ifdef __linux__
+    struct hidraw_devinfo info;
+
+    if ((ioctl(fd, HIDIOCGRAWINFO, &info) < 0) || (info.bustype != BUS_USB)) {
+        return false;
+    }
+
+    // The physical path of USB devices ends with the interface, e.g. "usb-0000:00:14.0-1/input3"
+    char phys [256];
+    memset(phys, 0, sizeof(phys));
+
+    if (ioctl(fd, HIDIOCGRAWPHYS(sizeof(phys) - 1), phys) < 0) {
+        return false;
+    }
+
+    const char* input = strrchr(phys, '/');
+
+    if ((!input) || (1 != sscanf(input, "/input%d", interface_number))) {
+        return false;
+    }
+
+    *vendor_id = (uint16_t) info.vendor;
+    *product_id = (uint16_t) info.product;
+    return true;
+#else
+    return false;
+#endif
+}
+
+int device_hid_write(device_hid_type* hid, const uint8_t* data, size_t length) {
+    if (hid->hid) {
+        return hid_write(hid->hid, data, length);
+    }
+
+    // Same as the hidraw backend of hidapi: the first byte is the report ID, which the kernel strips if it's 0
+    const ssize_t written = write(hid->fd, data, length);
+    return written < 0? -1 : (int) written;
+}
+
+int device_hid_read_timeout(device_hid_type* hid, uint8_t* data, size_t length, int timeout) {
+    if (hid->hid) {
+        return hid_read_timeout(hid->hid, data, length, timeout);
+    }
+
+    struct pollfd fds;
+    fds.fd = hid->fd;
+    fds.events = POLLIN;
+    fds.revents = 0;
+
+    const int ready = poll(&fds, 1, timeout);
+
+    if (ready == 0) {
+        return 0;
+    }
+
+    if ((ready < 0) || (fds.revents & (POLLERR | POLLHUP | POLLNVAL))) {
+        return -1;
+    }
+
+    const ssize_t transferred = read(hid->fd, data, length);
+
+    if (transferred < 0) {
+        return (errno == EAGAIN || errno == EINPROGRESS)? 0 : -1;
+    }
+
+    return (int) transferred;
+}
+
+void device_hid_close(device_hid_type* hid) {
+    if (!hid) {
+        return;
+    }
+
+    if (hid->hid) {
+        hid_close(hid->hid);
+    } else {
+        close(hid->fd);
+    }
+
+    free(hid);
+}
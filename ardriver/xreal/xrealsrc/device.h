@@ -33,6 +33,12 @@
 #include <cstdint>
 #endif
 
+#ifndef __cplusplus
+#include <stddef.h>
+#else
+#include <cstddef>
+#endif
+
 #ifdef __cplusplus
 extern "C" {
 #endif
@@ -41,6 +47,24 @@ bool device_init();
 
 void device_exit();
 
+// Connection to a HID interface, either opened by hidapi or given as an already opened hidraw file descriptor
+typedef struct device_hid_t device_hid_type;
+
+device_hid_type* device_hid_open_path(const char* path);
+
+// Duplicates fd, so the caller keeps ownership of it
+device_hid_type* device_hid_open_fd(int fd);
+
+// Gets the USB IDs and interface number of an opened hidraw node. Returns false if fd isn't a USB hidraw node
+bool device_hid_fd_info(int fd, uint16_t* vendor_id, uint16_t* product_id, int* interface_number);
+
+int device_hid_write(device_hid_type* hid, const uint8_t* data, size_t length);
+
+// Waits up to timeout milliseconds (-1 waits forever) for a report. Returns the number of bytes read, 0 on timeout and -1 on errors
+int device_hid_read_timeout(device_hid_type* hid, uint8_t* data, size_t length, int timeout);
+
+void device_hid_close(device_hid_type* hid);
+
 #ifdef __cplusplus
 } // extern "C"
 #endif
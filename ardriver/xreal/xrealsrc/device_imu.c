@@ -101,7 +101,7 @@ static bool send_payload(device_imu_type* device, uint16_t size, const uint8_t*
 		payload_size = device->max_payload_size;
 	}
 
-	int transferred = hid_write(device->handle, payload, payload_size);
+	int transferred = device_hid_write(device->handle, payload, payload_size);
 
 	if (transferred != payload_size) {
 		device_imu_error("Sending payload failed");
@@ -117,7 +117,7 @@ static bool recv_payload(device_imu_type* device, uint16_t size, uint8_t* payloa
 		payload_size = device->max_payload_size;
 	}
 
-	int transferred = hid_read(device->handle, payload, payload_size);
+	int transferred = device_hid_read_timeout(device->handle, payload, payload_size, -1);
 
 	if (transferred >= payload_size) {
 		transferred = payload_size;
@@ -298,50 +298,7 @@ static void init_device_imu_camera(device_imu_camera_type *camera, json_object *
 	camera->sensors = sensors;
 }
 
-device_imu_error_type device_imu_open(device_imu_type* device, device_imu_event_callback callback) {
-	if (!device) {
-		device_imu_error("No device");
-		return DEVICE_IMU_ERROR_NO_DEVICE;
-	}
-
-	memset(device, 0, sizeof(device_imu_type));
-	device->vendor_id 	= xreal_vendor_id;
-	device->product_id 	= 0;
-	device->callback 	= callback;
-
-	if (!device_init()) {
-		device_imu_error("Not initialized");
-		return DEVICE_IMU_ERROR_NOT_INITIALIZED;
-	}
-
-	struct hid_device_info* info = hid_enumerate(
-		device->vendor_id,
-		device->product_id
-	);
-
-	struct hid_device_info* it = info;
-	while (it) {
-		int interface_id = xreal_imu_interface_id(it->product_id);
-		if (interface_id != -1 && it->interface_number == interface_id) {
-#ifndef NDEBUG
-            printf("Found IMU device with product_id 0x%x on interface %d\n", it->product_id, interface_id);
-#endif
-			device->product_id = it->product_id;
-			device->handle = hid_open_path(it->path);
-			device->max_payload_size = xreal_imu_max_payload_size(device->product_id);
-			break;
-		}
-
-		it = it->next;
-	}
-
-	hid_free_enumeration(info);
-
-	if (!device->handle) {
-		device_imu_error("No handle");
-		return DEVICE_IMU_ERROR_NO_HANDLE;
-	}
-
+static device_imu_error_type device_imu_start(device_imu_type* device) {
 	if ((!send_payload_msg_signal(device, DEVICE_IMU_MSG_START_IMU_DATA, 0x0)) ||
         (!recv_payload_msg(device, DEVICE_IMU_MSG_START_IMU_DATA, 0, NULL))) {
 		device_imu_error("Failed sending payload to stop imu data stream");
@@ -486,6 +443,92 @@ device_imu_error_type device_imu_open(device_imu_type* device, device_imu_event_
 	return DEVICE_IMU_ERROR_NO_ERROR;
 }
 
+device_imu_error_type device_imu_open(device_imu_type* device, device_imu_event_callback callback) {
+	if (!device) {
+		device_imu_error("No device");
+		return DEVICE_IMU_ERROR_NO_DEVICE;
+	}
+
+	memset(device, 0, sizeof(device_imu_type));
+	device->vendor_id 	= xreal_vendor_id;
+	device->product_id 	= 0;
+	device->callback 	= callback;
+
+	if (!device_init()) {
+		device_imu_error("Not initialized");
+		return DEVICE_IMU_ERROR_NOT_INITIALIZED;
+	}
+
+	struct hid_device_info* info = hid_enumerate(
+		device->vendor_id,
+		device->product_id
+	);
+
+	struct hid_device_info* it = info;
+	while (it) {
+		int interface_id = xreal_imu_interface_id(it->product_id);
+		if (interface_id != -1 && it->interface_number == interface_id) {
+#ifndef NDEBUG
+            printf("Found IMU device with product_id 0x%x on interface %d\n", it->product_id, interface_id);
+#endif
+			device->product_id = it->product_id;
+			device->handle = device_hid_open_path(it->path);
+			device->max_payload_size = xreal_imu_max_payload_size(device->product_id);
+			break;
+		}
+
+		it = it->next;
+	}
+
+	hid_free_enumeration(info);
+
+	if (!device->handle) {
+		device_imu_error("No handle");
+		return DEVICE_IMU_ERROR_NO_HANDLE;
+	}
+
+	return device_imu_start(device);
+}
+
+device_imu_error_type device_imu_open_fd(device_imu_type* device, device_imu_event_callback callback, int fd) {
+	if (!device) {
+		device_imu_error("No device");
+		return DEVICE_IMU_ERROR_NO_DEVICE;
+	}
+
+	memset(device, 0, sizeof(device_imu_type));
+	device->vendor_id 	= xreal_vendor_id;
+	device->product_id 	= 0;
+	device->callback 	= callback;
+
+	uint16_t vendor_id;
+	uint16_t product_id;
+	int interface_number;
+
+	if ((!device_hid_fd_info(fd, &vendor_id, &product_id, &interface_number)) ||
+		(vendor_id != device->vendor_id) ||
+		(interface_number != xreal_imu_interface_id(product_id))) {
+		device_imu_error("No device");
+		return DEVICE_IMU_ERROR_NO_DEVICE;
+	}
+
+	if (!device_init()) {
+		device_imu_error("Not initialized");
+		return DEVICE_IMU_ERROR_NOT_INITIALIZED;
+	}
+
+	device->product_id = product_id;
+	device->max_payload_size = xreal_imu_max_payload_size(device->product_id);
+	device->handle = device_hid_open_fd(fd);
+
+	if (!device->handle) {
+		device_imu_error("No handle");
+		return DEVICE_IMU_ERROR_NO_HANDLE;
+	}
+
+	return device_imu_start(device);
+}
+
 device_imu_error_type device_imu_reset_calibration(device_imu_type* device) {
 	if (!device) {
 		device_imu_error("No device");
@@ -893,10 +936,11 @@ device_imu_error_type device_imu_calibrate(device_imu_type* device, uint32_t ite
 	while (iterations > 0) {
 		memset(&packet, 0, sizeof(device_imu_packet_type));
 
-		transferred = hid_read(
+		transferred = device_hid_read_timeout(
 			device->handle,
 			(uint8_t*) &packet,
-			sizeof(device_imu_packet_type)
+			sizeof(device_imu_packet_type),
+			-1
 		);
 
 		if (transferred == -1) {
@@ -995,7 +1039,7 @@ device_imu_error_type device_imu_read(device_imu_type* device, int timeout) {
 	device_imu_packet_type packet;
 	memset(&packet, 0, sizeof(device_imu_packet_type));
 
-	int transferred = hid_read_timeout(
+	int transferred = device_hid_read_timeout(
 		device->handle,
 		(uint8_t*) &packet,
 		sizeof(device_imu_packet_type),
@@ -1262,7 +1306,7 @@ device_imu_error_type device_imu_close(device_imu_type* device) {
 			device_imu_error("Failed sending payload to stop imu data stream");
 		}
 
-		hid_close(device->handle);
+		device_hid_close(device->handle);
 	}
 
 	memset(device, 0, sizeof(device_imu_type));
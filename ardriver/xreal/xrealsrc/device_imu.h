@@ -180,6 +180,9 @@ typedef struct device_imu_t device_imu_type;
 
 device_imu_error_type device_imu_open(device_imu_type* device, device_imu_event_callback callback);
 
+// Opens the device from an already opened hidraw node, e.g. one handed out by a privileged helper. The caller keeps ownership of fd
+device_imu_error_type device_imu_open_fd(device_imu_type* device, device_imu_event_callback callback, int fd);
+
 device_imu_error_type device_imu_reset_calibration(device_imu_type* device);
 
 device_imu_error_type device_imu_load_calibration(device_imu_type* device, const char* path);
@@ -54,7 +54,7 @@ static bool send_payload(device_mcu_type* device, uint8_t size, const uint8_t* p
 		payload_size = MAX_PACKET_SIZE;
 	}
 	
-	int transferred = hid_write(device->handle, payload, payload_size);
+	int transferred = device_hid_write(device->handle, payload, payload_size);
 	
 	if (transferred != payload_size) {
 		device_mcu_error("Sending payload failed");
@@ -70,7 +70,7 @@ static bool recv_payload(device_mcu_type* device, uint8_t size, uint8_t* payload
 		payload_size = MAX_PACKET_SIZE;
 	}
 	
-	int transferred = hid_read(device->handle, payload, payload_size);
+	int transferred = device_hid_read_timeout(device->handle, payload, payload_size, -1);
 	
 	if (transferred >= payload_size) {
 		transferred = payload_size;
@@ -174,49 +174,7 @@ static bool do_payload_action(device_mcu_type* device, uint16_t msgid, uint8_t l
 	return false;
 }
 
-device_mcu_error_type device_mcu_open(device_mcu_type* device, device_mcu_event_callback callback) {
-	if (!device) {
-		device_mcu_error("No device");
-		return DEVICE_MCU_ERROR_NO_DEVICE;
-	}
-	
-	memset(device, 0, sizeof(device_mcu_type));
-	device->vendor_id 	= xreal_vendor_id;
-	device->product_id 	= 0;
-	device->callback 	= callback;
-
-	if (!device_init()) {
-		device_mcu_error("Not initialized");
-		return DEVICE_MCU_ERROR_NOT_INITIALIZED;
-	}
-
-	struct hid_device_info* info = hid_enumerate(
-		device->vendor_id,
-		device->product_id
-	);
-
-	struct hid_device_info* it = info;
-	while (it) {
-		int interface_id = xreal_mcu_interface_id(it->product_id);
-		if (interface_id != -1 && it->interface_number == interface_id) {
-#ifndef NDEBUG
-            printf("Found MCU device with product_id 0x%x on interface %d\n", it->product_id, interface_id);
-#endif
-			device->product_id = it->product_id;
-			device->handle = hid_open_path(it->path);
-			break;
-		}
-
-		it = it->next;
-	}
-
-	hid_free_enumeration(info);
-
-	if (!device->handle) {
-		device_mcu_error("No handle");
-		return DEVICE_MCU_ERROR_NO_HANDLE;
-	}
-
+static device_mcu_error_type device_mcu_start(device_mcu_type* device) {
 	device_mcu_clear(device);
 
 	if (!send_payload_action(device, DEVICE_MCU_MSG_R_ACTIVATION_TIME, 0, NULL)) {
@@ -300,6 +258,90 @@ device_mcu_error_type device_mcu_open(device_mcu_type* device, device_mcu_event_
 	return DEVICE_MCU_ERROR_NO_ERROR;
 }
 
+device_mcu_error_type device_mcu_open(device_mcu_type* device, device_mcu_event_callback callback) {
+	if (!device) {
+		device_mcu_error("No device");
+		return DEVICE_MCU_ERROR_NO_DEVICE;
+	}
+	
+	memset(device, 0, sizeof(device_mcu_type));
+	device->vendor_id 	= xreal_vendor_id;
+	device->product_id 	= 0;
+	device->callback 	= callback;
+
+	if (!device_init()) {
+		device_mcu_error("Not initialized");
+		return DEVICE_MCU_ERROR_NOT_INITIALIZED;
+	}
+
+	struct hid_device_info* info = hid_enumerate(
+		device->vendor_id,
+		device->product_id
+	);
+
+	struct hid_device_info* it = info;
+	while (it) {
+		int interface_id = xreal_mcu_interface_id(it->product_id);
+		if (interface_id != -1 && it->interface_number == interface_id) {
+#ifndef NDEBUG
+            printf("Found MCU device with product_id 0x%x on interface %d\n", it->product_id, interface_id);
+#endif
+			device->product_id = it->product_id;
+			device->handle = device_hid_open_path(it->path);
+			break;
+		}
+
+		it = it->next;
+	}
+
+	hid_free_enumeration(info);
+
+	if (!device->handle) {
+		device_mcu_error("No handle");
+		return DEVICE_MCU_ERROR_NO_HANDLE;
+	}
+
+	return device_mcu_start(device);
+}
+
+device_mcu_error_type device_mcu_open_fd(device_mcu_type* device, device_mcu_event_callback callback, int fd) {
+	if (!device) {
+		device_mcu_error("No device");
+		return DEVICE_MCU_ERROR_NO_DEVICE;
+	}
+
+	memset(device, 0, sizeof(device_mcu_type));
+	device->vendor_id 	= xreal_vendor_id;
+	device->product_id 	= 0;
+	device->callback 	= callback;
+
+	uint16_t vendor_id;
+	uint16_t product_id;
+	int interface_number;
+
+	if ((!device_hid_fd_info(fd, &vendor_id, &product_id, &interface_number)) ||
+		(vendor_id != device->vendor_id) ||
+		(interface_number != xreal_mcu_interface_id(product_id))) {
+		device_mcu_error("No device");
+		return DEVICE_MCU_ERROR_NO_DEVICE;
+	}
+
+	if (!device_init()) {
+		device_mcu_error("Not initialized");
+		return DEVICE_MCU_ERROR_NOT_INITIALIZED;
+	}
+
+	device->product_id = product_id;
+	device->handle = device_hid_open_fd(fd);
+
+	if (!device->handle) {
+		device_mcu_error("No handle");
+		return DEVICE_MCU_ERROR_NO_HANDLE;
+	}
+
+	return device_mcu_start(device);
+}
+
 static void device_mcu_callback(device_mcu_type* device,
 							 uint64_t timestamp,
 							 device_mcu_event_type event,
@@ -335,7 +377,7 @@ device_mcu_error_type device_mcu_read(device_mcu_type* device, int timeout) {
 	device_mcu_packet_type packet;
 	memset(&packet, 0, sizeof(device_mcu_packet_type));
 	
-	int transferred = hid_read_timeout(
+	int transferred = device_hid_read_timeout(
 			device->handle,
 			(uint8_t*) &packet,
 			MAX_PACKET_SIZE,
@@ -721,7 +763,7 @@ device_mcu_error_type device_mcu_close(device_mcu_type* device) {
 	}
 	
 	if (device->handle) {
-		hid_close(device->handle);
+		device_hid_close(device->handle);
 	}
 	
 	memset(device, 0, sizeof(device_mcu_type));
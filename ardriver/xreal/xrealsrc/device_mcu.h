@@ -195,6 +195,9 @@ typedef struct device_mcu_t device_mcu_type;
 
 device_mcu_error_type device_mcu_open(device_mcu_type* device, device_mcu_event_callback callback);
 
+// Opens the device from an already opened hidraw node, e.g. one handed out by a privileged helper. The caller keeps ownership of fd
+device_mcu_error_type device_mcu_open_fd(device_mcu_type* device, device_mcu_event_callback callback, int fd);
+
 device_mcu_error_type device_mcu_clear(device_mcu_type* device);
 
 device_mcu_error_type device_mcu_read(device_mcu_type* device, int timeout);
@@ -1,7 +1,13 @@
 #include "device_imu.h"
+#include "device_mcu.h"
 
 extern void goIMUEventHandler(uint64_t, device_imu_event_type, device_imu_ahrs_type*);
+extern void goMCUEventHandler(uint64_t, device_mcu_event_type);
 
 void imuEventHandler(uint64_t timestamp, device_imu_event_type event, const device_imu_ahrs_type* ahrs) {
     goIMUEventHandler(timestamp, event, (device_imu_ahrs_type*)ahrs);
 }
+
+void mcuEventHandler(uint64_t timestamp, device_mcu_event_type event, uint8_t brightness, const char* msg) {
+    goMCUEventHandler(timestamp, event);
+}
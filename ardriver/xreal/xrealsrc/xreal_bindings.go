@@ -12,6 +12,7 @@ package xreal
 import "C"
 import (
 	"fmt"
+	"os"
 	"sync"
 	"time"
 
@@ -21,10 +22,63 @@ import (
 var (
 	deviceEventHandlerMutex = sync.Mutex{}
 	deviceEventListener     *commons.AREventListener
+
+	mcuEventHandlerMutex = sync.Mutex{}
+	mcuEventListener     *commons.AREventListener
+	mcuEventDevice       *C.struct_device_mcu_t
 )
 
+var mcuEventToButton = map[C.device_mcu_event_type]commons.ARButton{
+	C.DEVICE_MCU_EVENT_BRIGHTNESS_UP:   commons.ARButtonBrightnessUp,
+	C.DEVICE_MCU_EVENT_BRIGHTNESS_DOWN: commons.ARButtonBrightnessDown,
+	C.DEVICE_MCU_EVENT_VOLUME_UP:       commons.ARButtonVolumeUp,
+	C.DEVICE_MCU_EVENT_VOLUME_DOWN:     commons.ARButtonVolumeDown,
+	C.DEVICE_MCU_EVENT_BLEND_CYCLE:     commons.ARButtonBlendCycle,
+	C.DEVICE_MCU_EVENT_CONTROL_TOGGLE:  commons.ARButtonControlToggle,
+}
+
+var mcuBlendStates = map[C.uint8_t]commons.ARBlendState{
+	C.DEVICE_MCU_BLEND_STATE_LOW:    commons.ARBlendStateLow,
+	C.DEVICE_MCU_BLEND_STATE_MEDIUM: commons.ARBlendStateMedium,
+	C.DEVICE_MCU_BLEND_STATE_FULL:   commons.ARBlendStateFull,
+}
+
+// Display modes by refresh rate
+var (
+	monoDisplayModes = map[int]C.uint8_t{
+		60:  C.DEVICE_MCU_DISPLAY_MODE_1920x1080_60,
+		72:  C.DEVICE_MCU_DISPLAY_MODE_1920x1080_72,
+		90:  C.DEVICE_MCU_DISPLAY_MODE_1920x1080_90,
+		120: C.DEVICE_MCU_DISPLAY_MODE_1920x1080_120,
+	}
+
+	stereoDisplayModes = map[int]C.uint8_t{
+		60: C.DEVICE_MCU_DISPLAY_MODE_3840x1080_60_SBS,
+		72: C.DEVICE_MCU_DISPLAY_MODE_3840x1080_72_SBS,
+		90: C.DEVICE_MCU_DISPLAY_MODE_3840x1080_90_SBS,
+	}
+)
+
+//export goMCUEventHandler
+func goMCUEventHandler(_ C.uint64_t, event C.device_mcu_event_type) {
+	if mcuEventListener == nil {
+		return
+	}
+
+	if button, ok := mcuEventToButton[event]; ok && mcuEventListener.ButtonCallback != nil {
+		mcuEventListener.ButtonCallback(button)
+	}
+
+	// The MCU has already stored the new blend state by the time it reports the button
+	if event == C.DEVICE_MCU_EVENT_BLEND_CYCLE && mcuEventListener.BlendStateCallback != nil && mcuEventDevice != nil {
+		if blendState, ok := mcuBlendStates[mcuEventDevice.blend_state]; ok {
+			mcuEventListener.BlendStateCallback(blendState)
+		}
+	}
+}
+
 //export goIMUEventHandler
-func goIMUEventHandler(_ C.uint64_t, event_type C.device_imu_event_type, ahrs *C.struct_device_imu_ahrs_t) {
+func goIMUEventHandler(timestamp C.uint64_t, event_type C.device_imu_event_type, ahrs *C.struct_device_imu_ahrs_t) {
 	if deviceEventListener == nil {
 		return
 	}
@@ -34,20 +88,94 @@ func goIMUEventHandler(_ C.uint64_t, event_type C.device_imu_event_type, ahrs *C
 	}
 
 	orientation := C.device_imu_get_orientation(ahrs)
+
+	if deviceEventListener.OrientationCallback != nil {
+		deviceEventListener.OrientationCallback(commons.Quaternion{
+			X: float32(orientation.x),
+			Y: float32(orientation.y),
+			Z: float32(orientation.z),
+			W: float32(orientation.w),
+		}, uint64(timestamp))
+	}
+
 	euler := C.device_imu_get_euler(orientation)
 
-	deviceEventListener.PitchCallback(float32(euler.pitch))
-	deviceEventListener.RollCallback(float32(euler.roll))
-	deviceEventListener.YawCallback(float32(euler.yaw))
+	if deviceEventListener.PitchCallback != nil {
+		deviceEventListener.PitchCallback(float32(euler.pitch))
+	}
+
+	if deviceEventListener.RollCallback != nil {
+		deviceEventListener.RollCallback(float32(euler.roll))
+	}
+
+	if deviceEventListener.YawCallback != nil {
+		deviceEventListener.YawCallback(float32(euler.yaw))
+	}
 }
 
 // Implements commons.ARDevice
 type XrealDevice struct {
 	eventListener *commons.AREventListener
 	imuDevice     *C.struct_device_imu_t
+	mcuDevice     *C.struct_device_mcu_t
+	hidRawNodes   []*os.File
 	deviceIsOpen  bool
 }
 
+// Makes Initialize use already opened hidraw nodes (e.g. from the privileged helper) instead of opening the device itself.
+// The nodes are duplicated, so the caller can close them once Initialize returns
+func (device *XrealDevice) UseHIDRawNodes(nodes []*os.File) {
+	device.hidRawNodes = nodes
+}
+
+// Opens the MCU, which handles buttons and display modes. Both are optional, so failures here aren't fatal
+func (device *XrealDevice) openMCU() {
+	mcuDevice := &C.struct_device_mcu_t{}
+	status := C.device_mcu_error_type(C.DEVICE_MCU_ERROR_NO_DEVICE)
+
+	if len(device.hidRawNodes) == 0 {
+		status = C.device_mcu_open(mcuDevice, (*[0]byte)(C.mcuEventHandler))
+	}
+
+	for _, node := range device.hidRawNodes {
+		status = C.device_mcu_open_fd(mcuDevice, (*[0]byte)(C.mcuEventHandler), C.int(node.Fd()))
+
+		if status == C.DEVICE_MCU_ERROR_NO_ERROR {
+			break
+		}
+	}
+
+	if status != C.DEVICE_MCU_ERROR_NO_ERROR {
+		return
+	}
+
+	C.device_mcu_clear(mcuDevice)
+	device.mcuDevice = mcuDevice
+}
+
+// Reads button events from the MCU until the device is closed
+func (device *XrealDevice) readMCUEvents() {
+	for device.deviceIsOpen {
+		mcuEventHandlerMutex.Lock()
+		mcuEventListener = device.eventListener
+		mcuEventDevice = device.mcuDevice
+		status := C.device_mcu_read(device.mcuDevice, 100)
+		mcuEventHandlerMutex.Unlock()
+
+		// Malformed packets can happen now and then, so only give up once the device is gone
+		if status == C.DEVICE_MCU_ERROR_UNPLUGGED || status == C.DEVICE_MCU_ERROR_NO_HANDLE {
+			break
+		}
+	}
+
+	mcuEventHandlerMutex.Lock()
+	defer mcuEventHandlerMutex.Unlock()
+
+	C.device_mcu_close(device.mcuDevice)
+	device.mcuDevice = nil
+	mcuEventDevice = nil
+}
+
 func (device *XrealDevice) Initialize() error {
 	if device.deviceIsOpen {
 		return fmt.Errorf("device is already open")
@@ -55,9 +183,24 @@ func (device *XrealDevice) Initialize() error {
 
 	device.imuDevice = &C.struct_device_imu_t{}
 
+	status := C.device_imu_error_type(C.DEVICE_IMU_ERROR_NO_DEVICE)
+
 	// (*[0]byte) is a FUBAR way to cast a pointer to a function, but unsafe.Pointer doesn't work:
 	// cannot use unsafe.Pointer(_Cgo_ptr(_Cfpvar_fp_imuEventHandler)) (value of type unsafe.Pointer) as *[0]byte value in variable declaration
-	if C.DEVICE_IMU_ERROR_NO_ERROR != C.device_imu_open(device.imuDevice, (*[0]byte)(C.imuEventHandler)) {
+	if len(device.hidRawNodes) == 0 {
+		status = C.device_imu_open(device.imuDevice, (*[0]byte)(C.imuEventHandler))
+	}
+
+	// Only one of the nodes is the IMU, the others are rejected by device_imu_open_fd
+	for _, node := range device.hidRawNodes {
+		status = C.device_imu_open_fd(device.imuDevice, (*[0]byte)(C.imuEventHandler), C.int(node.Fd()))
+
+		if status == C.DEVICE_IMU_ERROR_NO_ERROR {
+			break
+		}
+	}
+
+	if status != C.DEVICE_IMU_ERROR_NO_ERROR {
 		return fmt.Errorf("failed to open IMU device")
 	}
 
@@ -65,6 +208,11 @@ func (device *XrealDevice) Initialize() error {
 	C.device_imu_calibrate(device.imuDevice, 1000, true, true, false)
 
 	device.deviceIsOpen = true
+	device.openMCU()
+
+	if device.mcuDevice != nil {
+		go device.readMCUEvents()
+	}
 
 	// let's hope this doesn't cause race conditions
 	go func() {
@@ -120,3 +268,32 @@ func (device *XrealDevice) Poll() error {
 func (device *XrealDevice) RegisterEventListeners(listener *commons.AREventListener) {
 	device.eventListener = listener
 }
+
+func (device *XrealDevice) SetStereoMode(enabled bool, refreshRate int) error {
+	displayModes := monoDisplayModes
+
+	if enabled {
+		displayModes = stereoDisplayModes
+	}
+
+	displayMode, ok := displayModes[refreshRate]
+
+	if !ok {
+		return fmt.Errorf("%d Hz is not supported in this display mode", refreshRate)
+	}
+
+	mcuEventHandlerMutex.Lock()
+	defer mcuEventHandlerMutex.Unlock()
+
+	if device.mcuDevice == nil {
+		return fmt.Errorf("MCU is not available")
+	}
+
+	device.mcuDevice.disp_mode = displayMode
+
+	if C.DEVICE_MCU_ERROR_NO_ERROR != C.device_mcu_update_display_mode(device.mcuDevice) {
+		return fmt.Errorf("failed to update display mode")
+	}
+
+	return nil
+}
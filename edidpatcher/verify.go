@@ -0,0 +1,223 @@
+package edidpatcher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const (
+	edidBlockSize           = 128
+	edidMaxExtensionBlocks  = 255
+	ctaExtensionTag         = 0x02
+	ctaVendorSpecificTag    = 0x03
+	detailedTimingSize      = 18
+	msftVSDBPayloadLength   = 0x15
+	edidExtensionCountIndex = 126
+)
+
+var (
+	edidHeader = []byte{0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00}
+	msftOUI    = []byte{0x5C, 0x12, 0xCA}
+)
+
+// Verifies that a patched EDID (see PatchEDIDToBeSpecialized) is well-formed and still describes the same display as the original EDID.
+//
+// All problems that are found are returned together (see errors.Join), so callers can report everything at once.
+func Verify(originalEDID []byte, patchedEDID []byte) error {
+	if err := VerifyStructure(patchedEDID); err != nil {
+		return err
+	}
+
+	problems := []error{}
+
+	if len(originalEDID) < edidBlockSize {
+		return fmt.Errorf("original EDID is too short (%d bytes)", len(originalEDID))
+	}
+
+	// Everything in the base block except the extension count and checksum must be untouched
+	if !bytes.Equal(originalEDID[:edidExtensionCountIndex], patchedEDID[:edidExtensionCountIndex]) {
+		problems = append(problems, fmt.Errorf("base EDID block was modified (timings, descriptors, or vendor data changed)"))
+	}
+
+	patchedTimings := [][]byte{}
+
+	for blockStart := edidBlockSize; blockStart+edidBlockSize <= len(patchedEDID); blockStart += edidBlockSize {
+		block := patchedEDID[blockStart : blockStart+edidBlockSize]
+
+		if block[0] == ctaExtensionTag {
+			patchedTimings = append(patchedTimings, ctaDetailedTimings(block)...)
+		}
+	}
+
+	for blockStart := edidBlockSize; blockStart+edidBlockSize <= len(originalEDID); blockStart += edidBlockSize {
+		block := originalEDID[blockStart : blockStart+edidBlockSize]
+
+		if block[0] != ctaExtensionTag {
+			continue
+		}
+
+		for _, timing := range ctaDetailedTimings(block) {
+			found := false
+
+			for _, patchedTiming := range patchedTimings {
+				if bytes.Equal(timing, patchedTiming) {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				problems = append(problems, fmt.Errorf("detailed timing descriptor from extension block %d was not preserved", blockStart/edidBlockSize))
+			}
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// Verifies that an EDID containing the Microsoft specialized display extension is well-formed.
+//
+// This checks the header, the checksum of every block, the extension count, the detailed timing descriptor offsets of CTA extension blocks,
+// and the presence and shape of the MSFT vendor specific data block. It does not need the original EDID.
+func VerifyStructure(edid []byte) error {
+	if len(edid) < edidBlockSize*2 {
+		return fmt.Errorf("EDID is too short to contain an extension block (%d bytes)", len(edid))
+	}
+
+	if len(edid)%edidBlockSize != 0 {
+		return fmt.Errorf("EDID length (%d bytes) is not a multiple of %d", len(edid), edidBlockSize)
+	}
+
+	if len(edid)/edidBlockSize-1 > edidMaxExtensionBlocks {
+		return fmt.Errorf("EDID has too many extension blocks (%d)", len(edid)/edidBlockSize-1)
+	}
+
+	problems := []error{}
+
+	if !bytes.Equal(edid[:len(edidHeader)], edidHeader) {
+		problems = append(problems, fmt.Errorf("base EDID block has an invalid header"))
+	}
+
+	for blockStart := 0; blockStart < len(edid); blockStart += edidBlockSize {
+		block := edid[blockStart : blockStart+edidBlockSize]
+
+		if checksum := CalculateEDIDChecksum(block); checksum != block[edidBlockSize-1] {
+			problems = append(problems, fmt.Errorf("block %d has an invalid checksum (expected 0x%02X, got 0x%02X)", blockStart/edidBlockSize, checksum, block[edidBlockSize-1]))
+		}
+	}
+
+	if extensionCount := int(edid[edidExtensionCountIndex]); extensionCount != len(edid)/edidBlockSize-1 {
+		problems = append(problems, fmt.Errorf("extension count in byte 126 is %d, but the EDID contains %d extension block(s)", extensionCount, len(edid)/edidBlockSize-1))
+	}
+
+	foundMSFTBlock := false
+
+	for blockStart := edidBlockSize; blockStart < len(edid); blockStart += edidBlockSize {
+		block := edid[blockStart : blockStart+edidBlockSize]
+		blockNumber := blockStart / edidBlockSize
+
+		if block[0] != ctaExtensionTag {
+			continue
+		}
+
+		hasMSFTBlock, err := verifyCTABlock(block)
+
+		if err != nil {
+			problems = append(problems, fmt.Errorf("CTA extension block %d: %w", blockNumber, err))
+		}
+
+		if hasMSFTBlock {
+			if foundMSFTBlock {
+				problems = append(problems, fmt.Errorf("CTA extension block %d: duplicate MSFT vendor specific data block", blockNumber))
+			}
+
+			foundMSFTBlock = true
+		}
+	}
+
+	if !foundMSFTBlock {
+		problems = append(problems, fmt.Errorf("no MSFT vendor specific data block found in any CTA extension block"))
+	}
+
+	return errors.Join(problems...)
+}
+
+// Checks the DTD offset, data block collection, and MSFT VSDB of a single CTA extension block. Returns whether the MSFT VSDB was found.
+func verifyCTABlock(block []byte) (bool, error) {
+	dtdOffset := int(block[2])
+
+	// An offset of 0 means there are neither data blocks nor DTDs
+	if dtdOffset == 0 {
+		return false, nil
+	}
+
+	if dtdOffset < 4 || dtdOffset > edidBlockSize-1 {
+		return false, fmt.Errorf("DTD offset %d is out of range", dtdOffset)
+	}
+
+	hasMSFTBlock := false
+
+	for position := 4; position < dtdOffset; {
+		tag := block[position] >> 5
+		length := int(block[position] & 0x1F)
+
+		if position+1+length > dtdOffset {
+			return hasMSFTBlock, fmt.Errorf("data block at offset %d (length %d) overruns the DTD offset %d", position, length, dtdOffset)
+		}
+
+		payload := block[position+1 : position+1+length]
+
+		if tag == ctaVendorSpecificTag && length >= len(msftOUI) && bytes.Equal(payload[:len(msftOUI)], msftOUI) {
+			if length != msftVSDBPayloadLength {
+				return hasMSFTBlock, fmt.Errorf("MSFT vendor specific data block has length %d (expected %d)", length, msftVSDBPayloadLength)
+			}
+
+			if version := payload[3]; version < 1 || version > 3 {
+				return hasMSFTBlock, fmt.Errorf("MSFT vendor specific data block has unknown version %d", version)
+			}
+
+			if bytes.Equal(payload[5:5+16], make([]byte, 16)) {
+				return hasMSFTBlock, fmt.Errorf("MSFT vendor specific data block has an empty container ID")
+			}
+
+			hasMSFTBlock = true
+		}
+
+		position += 1 + length
+	}
+
+	// Every DTD must fit fully before the checksum byte
+	for position := dtdOffset; position+1 < edidBlockSize-1; position += detailedTimingSize {
+		if block[position] == 0 && block[position+1] == 0 {
+			break
+		}
+
+		if position+detailedTimingSize > edidBlockSize-1 {
+			return hasMSFTBlock, fmt.Errorf("detailed timing descriptor at offset %d is truncated", position)
+		}
+	}
+
+	return hasMSFTBlock, nil
+}
+
+// Returns all detailed timing descriptors contained in a CTA extension block.
+func ctaDetailedTimings(block []byte) [][]byte {
+	timings := [][]byte{}
+	dtdOffset := int(block[2])
+
+	if dtdOffset < 4 {
+		return timings
+	}
+
+	for position := dtdOffset; position+detailedTimingSize <= edidBlockSize-1; position += detailedTimingSize {
+		// A pixel clock of 0 marks the end of the DTD list (or padding)
+		if block[position] == 0 && block[position+1] == 0 {
+			break
+		}
+
+		timings = append(timings, block[position:position+detailedTimingSize])
+	}
+
+	return timings
+}
@@ -16,9 +16,10 @@ import (
 )
 
 var (
-	// EVDI Event data
-	cEventToGoEventMapping = map[unsafe.Pointer]*EvdiEventContext{}
-	activeLogger           = &EvdiLogger{
+	// EVDI Event data. Guarded by cEventToGoEventMappingMutex, as events are handled on other goroutines than the ones (un)registering handlers
+	cEventToGoEventMapping      = map[unsafe.Pointer]*EvdiEventContext{}
+	cEventToGoEventMappingMutex = sync.RWMutex{}
+	activeLogger                = &EvdiLogger{
 		Log: func(msg string) {
 			fmt.Printf("evdi: %s\n", msg)
 		},
@@ -30,6 +31,9 @@ var (
 	StridePixelFormatRGBA32 = 4
 )
 
+// Maximum number of dirty rectangles EVDI reports for a single grab (MAX_DIRTS in evdi_lib.c)
+const MaxDirtyRects = 16
+
 type EvdiLogger struct {
 	Log func(message string)
 }
@@ -92,9 +96,18 @@ type EvdiDisplayRect struct {
 	cDisplayRect *C.struct_evdi_rect
 }
 
+// Finds the Go event context for a C event context
+func lookupEventContext(userData unsafe.Pointer) (*EvdiEventContext, bool) {
+	cEventToGoEventMappingMutex.RLock()
+	defer cEventToGoEventMappingMutex.RUnlock()
+
+	goData, ok := cEventToGoEventMapping[userData]
+	return goData, ok
+}
+
 //export goDPMSHandler
 func goDPMSHandler(event C.int, userData unsafe.Pointer) {
-	goData, ok := cEventToGoEventMapping[userData]
+	goData, ok := lookupEventContext(userData)
 
 	if !ok {
 		panic("could not find Go event from C event map for EvdiEventContext")
@@ -109,7 +122,7 @@ func goDPMSHandler(event C.int, userData unsafe.Pointer) {
 
 //export goModeChangedHandler
 func goModeChangedHandler(mode C.struct_evdi_mode, userData unsafe.Pointer) {
-	goData, ok := cEventToGoEventMapping[userData]
+	goData, ok := lookupEventContext(userData)
 
 	if !ok {
 		panic("could not find Go event from C event map for EvdiEventContext")
@@ -130,7 +143,7 @@ func goModeChangedHandler(mode C.struct_evdi_mode, userData unsafe.Pointer) {
 
 //export goUpdateReadyHandler
 func goUpdateReadyHandler(event C.int, userData unsafe.Pointer) {
-	goData, ok := cEventToGoEventMapping[userData]
+	goData, ok := lookupEventContext(userData)
 
 	if !ok {
 		panic("could not find Go event from C event map for EvdiEventContext")
@@ -145,7 +158,7 @@ func goUpdateReadyHandler(event C.int, userData unsafe.Pointer) {
 
 //export goCRTCStateHandler
 func goCRTCStateHandler(event C.int, userData unsafe.Pointer) {
-	goData, ok := cEventToGoEventMapping[userData]
+	goData, ok := lookupEventContext(userData)
 
 	if !ok {
 		panic("could not find Go event from C event map for EvdiEventContext")
@@ -160,7 +173,7 @@ func goCRTCStateHandler(event C.int, userData unsafe.Pointer) {
 
 //export goCursorSetHandler
 func goCursorSetHandler(cursor C.struct_evdi_cursor_set, userData unsafe.Pointer) {
-	goData, ok := cEventToGoEventMapping[userData]
+	goData, ok := lookupEventContext(userData)
 
 	if !ok {
 		panic("could not find Go event from C event map for EvdiEventContext")
@@ -176,6 +189,9 @@ func goCursorSetHandler(cursor C.struct_evdi_cursor_set, userData unsafe.Pointer
 	safeBuffer := make([]byte, cursor.buffer_length)
 	copy(safeBuffer, pointerBuffer)
 
+	// libevdi allocates the cursor buffer for us, and leaves freeing it to the handler
+	C.free(unsafe.Pointer(cursor.buffer))
+
 	goData.CursorSetHandler(&EvdiCursorSet{
 		HotX:        int32(cursor.hot_x),
 		HotY:        int32(cursor.hot_y),
@@ -190,7 +206,7 @@ func goCursorSetHandler(cursor C.struct_evdi_cursor_set, userData unsafe.Pointer
 
 //export goCursorMoveHandler
 func goCursorMoveHandler(cursor C.struct_evdi_cursor_move, userData unsafe.Pointer) {
-	goData, ok := cEventToGoEventMapping[userData]
+	goData, ok := lookupEventContext(userData)
 
 	if !ok {
 		panic("could not find Go event from C event map for EvdiEventContext")
@@ -205,7 +221,7 @@ func goCursorMoveHandler(cursor C.struct_evdi_cursor_move, userData unsafe.Point
 
 //export goDDCCIDataHandler
 func goDDCCIDataHandler(data C.struct_evdi_ddcci_data, userData unsafe.Pointer) {
-	goData, ok := cEventToGoEventMapping[userData]
+	goData, ok := lookupEventContext(userData)
 
 	if !ok {
 		panic("could not find Go event from C event map for EvdiEventContext")
@@ -290,7 +306,10 @@ func (node *EvdiNode) RegisterEventHandler(handler *EvdiEventContext) error {
 
 	handler.cEventContext.user_data = unsafe.Pointer(handler.cEventContext)
 
+	cEventToGoEventMappingMutex.Lock()
 	cEventToGoEventMapping[unsafe.Pointer(handler.cEventContext)] = handler
+	cEventToGoEventMappingMutex.Unlock()
+
 	node.eventContexts = append(node.eventContexts, handler)
 
 	return nil
@@ -298,11 +317,15 @@ func (node *EvdiNode) RegisterEventHandler(handler *EvdiEventContext) error {
 
 // Unregisters an event handler for the device node.
 func (node *EvdiNode) UnregisterEventHandler(handler *EvdiEventContext) error {
+	cEventToGoEventMappingMutex.Lock()
+
 	if _, ok := cEventToGoEventMapping[unsafe.Pointer(handler.cEventContext)]; !ok {
+		cEventToGoEventMappingMutex.Unlock()
 		return fmt.Errorf("could not find event map")
 	}
 
 	delete(cEventToGoEventMapping, unsafe.Pointer(handler.cEventContext))
+	cEventToGoEventMappingMutex.Unlock()
 
 	if handler.cEventContext == nil {
 		return fmt.Errorf("cEventContext pointer is somehow nil! Please report this bug at https://git.lunr.sh/UnrealXR/unrealxr")
@@ -320,7 +343,7 @@ func (node *EvdiNode) UnregisterEventHandler(handler *EvdiEventContext) error {
 
 // Handles events for the device node. Be sure to wait for events to be ready before calling this function (WaitUntilEventsReady).
 func (node *EvdiNode) HandleEvents(handler *EvdiEventContext) error {
-	if _, ok := cEventToGoEventMapping[unsafe.Pointer(handler.cEventContext)]; !ok {
+	if _, ok := lookupEventContext(unsafe.Pointer(handler.cEventContext)); !ok {
 		return fmt.Errorf("could not find event map")
 	}
 
@@ -387,7 +410,8 @@ func (node *EvdiNode) CreateBuffer(width, height, stride int, rect *EvdiDisplayR
 	cBuffer := C.malloc(C.size_t(width * height * stride))
 	normalBuffer := unsafe.Slice((*byte)(cBuffer), width*height*stride)
 
-	rawDisplayRect := C.malloc(C.size_t(C.sizeof_struct_evdi_buffer))
+	// evdi_grab_pixels writes up to MaxDirtyRects rects, so we need room for all of them
+	rawDisplayRect := C.malloc(C.size_t(C.sizeof_struct_evdi_rect * MaxDirtyRects))
 
 	if rawDisplayRect == nil {
 		panic("malloc() failed for rawDisplayRect")
@@ -417,6 +441,7 @@ func (node *EvdiNode) CreateBuffer(width, height, stride int, rect *EvdiDisplayR
 		Height: height,
 		Stride: stride,
 
+		rect:               rect,
 		internalEvdiBuffer: &evdiBuffer,
 	}
 
@@ -474,6 +499,32 @@ func (node *EvdiNode) GrabPixels(rect *EvdiDisplayRect) (int, error) {
 	return rectNum, nil
 }
 
+// Grabs pixels following the most recent update request (see EvdiNode.RequestUpdate), and returns the rectangles that changed since the previous grab.
+//
+// rect must be the rect the buffer was created with. Only the changed regions are written to the buffer.
+func (node *EvdiNode) GrabDirtyRects(rect *EvdiDisplayRect) ([]EvdiDisplayRect, error) {
+	rectNum, err := node.GrabPixels(rect)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rectNum = min(rectNum, MaxDirtyRects)
+	cDirtyRects := unsafe.Slice(rect.cDisplayRect, rectNum)
+	dirtyRects := make([]EvdiDisplayRect, rectNum)
+
+	for i, cDirtyRect := range cDirtyRects {
+		dirtyRects[i] = EvdiDisplayRect{
+			X1: int(cDirtyRect.x1),
+			Y1: int(cDirtyRect.y1),
+			X2: int(cDirtyRect.x2),
+			Y2: int(cDirtyRect.y2),
+		}
+	}
+
+	return dirtyRects, nil
+}
+
 // Requests an update for a buffer. The buffer must be already registered with the library. If true, the update is ready. If false, the update is not ready.
 func (node *EvdiNode) RequestUpdate(buffer *EvdiBuffer) bool {
 	return bool(C.evdi_request_update(node.handle, C.int(buffer.ID)))